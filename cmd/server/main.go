@@ -2,15 +2,23 @@ package main
 
 import (
 	_ "HomeWork5/docs"
+	"HomeWork5/internal/message"
+	"HomeWork5/internal/presence"
+	"HomeWork5/internal/room"
 	"HomeWork5/internal/storage"
 	"HomeWork5/internal/user"
 	"HomeWork5/internal/ws"
 	"HomeWork5/router"
+	"context"
+	"errors"
 	"github.com/joho/godotenv"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 // @title           RESTful Chat Web Server
@@ -30,6 +38,10 @@ import (
 
 // @securityDefinitions.basic  BasicAuth
 
+// @securityDefinitions.apikey  BearerAuth
+// @in                          header
+// @name                        Authorization
+
 // @externalDocs.description  OpenAPI
 // @externalDocs.url          http://localhost:8080/swagger/index.html
 
@@ -51,7 +63,7 @@ func main() {
 
 	db, err := storage.NewDB()
 	if err != nil {
-		log.Error("Failed to connect to database:", err)
+		log.Error("Failed to connect to database", slog.String("error", err.Error()))
 	}
 	defer db.Close()
 
@@ -59,20 +71,58 @@ func main() {
 	userService := user.NewService(userRep)
 	userHandler := user.NewHandler(log, userService)
 
-	hub := ws.NewHub()
-	wsHandler := ws.NewHandler(log, hub)
+	roomRep := room.NewRepository(db)
+	messageRep := message.NewRepository(db)
+	presenceRep := presence.NewRepository(db)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	wsConfig, err := ws.ConfigFromEnv()
+	if err != nil {
+		log.Error("Invalid WebSocket configuration", slog.String("error", err.Error()))
+		return
+	}
+
+	var broker ws.Broker = ws.NewLocalBroker()
+	if wsConfig.Broker == ws.BrokerPostgres {
+		broker = ws.NewPostgresBroker(db, storage.DSN())
+	}
+
+	hub := ws.NewHub(roomRep, messageRep, presenceRep, broker, wsConfig)
+	hubDone := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(hubDone)
+	}()
+	wsHandler := ws.NewHandler(log, hub, userService)
+
+	messageService := message.NewService(messageRep, hub, hub)
+	messageHandler := message.NewHandler(log, messageService)
 
-	r := router.InitRouter(log, userHandler, wsHandler)
+	r := router.InitRouter(log, userHandler, messageHandler, wsHandler)
 	server := http.Server{
 		Addr:    "0.0.0.0:8080",
 		Handler: r,
 	}
 
-	err = server.ListenAndServe()
-	if err != nil {
-		log.Error("Failed to start server:", err)
-	}
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("Failed to start server", slog.String("error", err.Error()))
+			stop()
+		}
+	}()
 
+	<-ctx.Done()
+	log.Info("shutting down web server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Error("Failed to shut down server", slog.String("error", err.Error()))
+	}
+	<-hubDone
 }
 
 func configureLogger(env string, fileName *os.File) *slog.Logger {
@@ -0,0 +1,268 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 100
+
+	// GeneralRoomID is the shared room behind POST /messages and GET /messages.
+	GeneralRoomID = "general"
+
+	MaxContentLength = 4000
+	MaxEmojiLength   = 32
+
+	// RoomMention is the name that mentions every member of the room.
+	RoomMention = "room"
+)
+
+var (
+	ErrEmptyContent      = errors.New("message content is empty")
+	ErrContentTooLong    = errors.New("message content is too long")
+	ErrRecipientNotFound = errors.New("recipient not found")
+	ErrSelfMessage       = errors.New("can't send a direct message to yourself")
+	ErrMessageNotFound   = errors.New("message not found")
+	ErrMessageDeleted    = errors.New("message was deleted")
+	ErrParentNotFound    = errors.New("parent message not found")
+	ErrInvalidEmoji      = errors.New("invalid emoji")
+	ErrReactionExists    = errors.New("reaction already exists")
+	ErrReactionNotFound  = errors.New("reaction not found")
+	ErrBanned            = errors.New("banned from the room")
+	ErrMuted             = errors.New("muted in the room")
+	ErrRoomArchived      = errors.New("room is archived")
+	ErrNoAccess          = errors.New("no access to the room")
+)
+
+// Message is a message sent to a room. Deleted messages stay as tombstones
+// with an empty content so that paging over them keeps working. Replies have
+// ParentID set to the message that started their thread, which counts its
+// live replies in ReplyCount. Mentions lists the @mentions of its content.
+type Message struct {
+	ID         int64      `json:"id"`
+	RoomID     string     `json:"roomId"`
+	UserID     int64      `json:"userId"`
+	Username   string     `json:"username"`
+	Content    string     `json:"content"`
+	ParentID   *int64     `json:"parentId,omitempty"`
+	ReplyCount int        `json:"replyCount"`
+	Reactions  Reactions  `json:"reactions,omitempty"`
+	Mentions   Mentions   `json:"mentions,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	EditedAt   *time.Time `json:"editedAt,omitempty"`
+	DeletedAt  *time.Time `json:"deletedAt,omitempty"`
+}
+
+// Reaction aggregates the users that reacted to a message with an emoji, in
+// the order they reacted.
+type Reaction struct {
+	Emoji     string   `json:"emoji"`
+	Count     int      `json:"count"`
+	UserIDs   []int64  `json:"userIds"`
+	Usernames []string `json:"usernames"`
+}
+
+// Reactions are the reactions to a message, ordered by their first use.
+type Reactions []*Reaction
+
+type MentionKind string
+
+const (
+	MentionUser MentionKind = "user"
+	MentionRoom MentionKind = "room"
+)
+
+// Mention is an @username or @room in the content of a message. Offset and
+// Length count characters, the @ included. User mentions name the user they
+// resolved to, names matching nobody aren't mentions.
+type Mention struct {
+	Kind     MentionKind `json:"kind"`
+	UserID   int64       `json:"userId,omitempty"`
+	Username string      `json:"username,omitempty"`
+	Offset   int         `json:"offset"`
+	Length   int         `json:"length"`
+}
+
+// Mentions are the mentions of a message in the order they appear.
+type Mentions []*Mention
+
+// ReactionReq adds a reaction to a message.
+type ReactionReq struct {
+	Emoji string `json:"emoji"`
+}
+
+// Edit is a previous version of a message, saved when it was edited or
+// deleted.
+type Edit struct {
+	ID         int64     `json:"id"`
+	MessageID  int64     `json:"messageId"`
+	Content    string    `json:"content"`
+	EditedBy   int64     `json:"editedBy"`
+	EditorName string    `json:"editorName"`
+	EditedAt   time.Time `json:"editedAt"`
+}
+
+type DirectMessage struct {
+	ID          int64      `json:"id"`
+	SenderID    int64      `json:"senderId"`
+	SenderName  string     `json:"senderName"`
+	RecipientID int64      `json:"recipientId"`
+	Content     string     `json:"content"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ReadAt      *time.Time `json:"readAt,omitempty"`
+}
+
+// Conversation summarizes the direct messages exchanged with another user.
+// LastReadID is the last message from the other user that was read, and
+// PeerLastReadID the last one they read.
+type Conversation struct {
+	UserID         int64          `json:"userId"`
+	Username       string         `json:"username"`
+	LastMessage    *DirectMessage `json:"lastMessage"`
+	UnreadCount    int            `json:"unreadCount"`
+	LastReadID     int64          `json:"lastReadId"`
+	PeerLastReadID int64          `json:"peerLastReadId"`
+}
+
+// ReadReceipt tells that a user has read a room, or the conversation with
+// PeerID, up to LastReadID.
+type ReadReceipt struct {
+	RoomID     string    `json:"roomId,omitempty"`
+	PeerID     int64     `json:"peerId,omitempty"`
+	UserID     int64     `json:"userId"`
+	Username   string    `json:"username"`
+	LastReadID int64     `json:"lastReadId"`
+	ReadAt     time.Time `json:"readAt"`
+}
+
+// ReadReq moves a read position to MessageID, or to the latest message when
+// it's omitted.
+type ReadReq struct {
+	MessageID int64 `json:"messageId"`
+}
+
+type MessageReq struct {
+	Content string `json:"content"`
+	// ParentID makes a room message a reply in the thread of that message.
+	// Direct messages and edits ignore it.
+	ParentID int64 `json:"parentId,omitempty"`
+}
+
+type MessagesRes struct {
+	Messages []*Message `json:"messages"`
+	HasMore  bool       `json:"hasMore"`
+}
+
+type DirectMessagesRes struct {
+	Messages []*DirectMessage `json:"messages"`
+	HasMore  bool             `json:"hasMore"`
+}
+
+type ConversationsRes struct {
+	Conversations []*Conversation `json:"conversations"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// Page selects a window of messages by id. Before and After are exclusive
+// cursors, zero means unset. Without cursors the latest Limit messages are
+// returned. Results are always ordered from oldest to newest.
+type Page struct {
+	Before int64
+	After  int64
+	Limit  int
+}
+
+// Normalize clamps the limit into [1, MaxLimit].
+func (p Page) Normalize() Page {
+	if p.Limit <= 0 {
+		p.Limit = DefaultLimit
+	}
+	if p.Limit > MaxLimit {
+		p.Limit = MaxLimit
+	}
+	return p
+}
+
+type Repository interface {
+	// CreateMessage stores a message. A reply to a reply joins the thread of
+	// its parent, so ParentID always ends up pointing at the thread start.
+	// The mentions in its content are stored along, see ParseMentions.
+	CreateMessage(ctx context.Context, m *Message) (*Message, error)
+	// ListRoomMessages returns up to page.Limit messages outside of threads and
+	// whether more exist past the returned window in the paging direction.
+	ListRoomMessages(ctx context.Context, roomID string, page Page) ([]*Message, bool, error)
+	// ListRoomMessagesAfter returns up to limit messages of the room newer
+	// than after, replies included, oldest first, and whether more follow.
+	// Resumed sessions walk it so that nothing sent meanwhile is skipped.
+	ListRoomMessagesAfter(ctx context.Context, roomID string, after int64, limit int) ([]*Message, bool, error)
+	// ListReplies pages over the replies in the thread of a message the same
+	// way as ListRoomMessages.
+	ListReplies(ctx context.Context, parentID int64, page Page) ([]*Message, bool, error)
+	GetMessage(ctx context.Context, id int64) (*Message, error)
+	// UpdateMessage replaces the content of a message and its mentions,
+	// keeping the previous content in its history.
+	UpdateMessage(ctx context.Context, id, editorID int64, content string) (*Message, error)
+	// DeleteMessage turns a message into a tombstone, keeping its content in
+	// its history.
+	DeleteMessage(ctx context.Context, id, deletedBy int64) (*Message, error)
+	ListEdits(ctx context.Context, messageID int64) ([]*Edit, error)
+	// AddReaction fails with ErrReactionExists when the user already reacted
+	// to the message with the emoji.
+	AddReaction(ctx context.Context, messageID, userID int64, emoji string) error
+	RemoveReaction(ctx context.Context, messageID, userID int64, emoji string) error
+	// MentionedUsers returns the users to notify of the mentions in a message:
+	// those mentioned by name that may read its room and, for @room, the
+	// members of the room. The author is left out.
+	MentionedUsers(ctx context.Context, messageID int64) ([]int64, error)
+	// ListMentions pages over the live messages mentioning the user in rooms
+	// they may read.
+	ListMentions(ctx context.Context, userID int64, page Page) ([]*Message, bool, error)
+
+	CreateDirectMessage(ctx context.Context, dm *DirectMessage) (*DirectMessage, error)
+	ListDirectMessages(ctx context.Context, userID, peerID int64, page Page) ([]*DirectMessage, bool, error)
+	ListConversations(ctx context.Context, userID int64) ([]*Conversation, error)
+	// MarkConversationRead marks the messages received from peerID up to upTo,
+	// or all of them when it's 0, as read. It returns the last message that
+	// became read, 0 if there was none.
+	MarkConversationRead(ctx context.Context, userID, peerID, upTo int64) (int64, error)
+}
+
+// Publisher fans stored messages out to live connections.
+type Publisher interface {
+	Publish(m *Message)
+	PublishDirect(dm *DirectMessage)
+	PublishReceipt(r *ReadReceipt)
+}
+
+// AccessChecker applies the restrictions of a room to messages read and
+// posted over REST, the same way they apply to live connections.
+type AccessChecker interface {
+	// CanRead fails with ErrBanned or ErrNoAccess when the user may not read
+	// the room.
+	CanRead(ctx context.Context, roomID string, userID int64, admin bool) error
+	// CanPost fails like CanRead, or with ErrRoomArchived or ErrMuted when
+	// the user may read the room but not post in it.
+	CanPost(ctx context.Context, roomID string, userID int64, admin bool) error
+}
+
+type Service interface {
+	SendMessage(ctx context.Context, userID int64, username string, admin bool, req *MessageReq) (*Message, error)
+	GetMessages(ctx context.Context, userID int64, admin bool, page Page) (*MessagesRes, error)
+	GetMentions(ctx context.Context, userID int64, page Page) (*MessagesRes, error)
+
+	SendDirectMessage(ctx context.Context, senderID int64, senderName string, recipientID int64, req *MessageReq) (*DirectMessage, error)
+	GetConversations(ctx context.Context, userID int64) (*ConversationsRes, error)
+	// GetConversation returns a page of messages exchanged with peerID and
+	// marks the ones received from them as read.
+	GetConversation(ctx context.Context, userID int64, username string, peerID int64, page Page) (*DirectMessagesRes, error)
+	// MarkConversationRead marks the messages received from peerID as read up
+	// to req.MessageID and returns the receipt sent to them, nil when nothing
+	// was left to read.
+	MarkConversationRead(ctx context.Context, userID int64, username string, peerID int64, req *ReadReq) (*ReadReceipt, error)
+}
@@ -0,0 +1,407 @@
+package message
+
+import (
+	"HomeWork5/internal/middleware"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+type Handler struct {
+	Service
+	*slog.Logger
+}
+
+func NewHandler(log *slog.Logger, s Service) *Handler {
+	return &Handler{s, log}
+}
+
+func (h *Handler) sendErrorResponse(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
+	logResponseStatusError(h.Logger, message, statusCode)
+}
+
+func (h *Handler) sendSuccessResponse(w http.ResponseWriter, body interface{}, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+	logResponseSuccess(h.Logger, message, statusCode)
+}
+
+func logResponseStatusError(log *slog.Logger, message string, statusCode int) {
+	log.Error("Request error", "status", statusCode, "error", message)
+}
+
+func logResponseSuccess(log *slog.Logger, message string, statusCode int) {
+	log.Info("Request success", slog.Int("status", statusCode), slog.String("message", message))
+}
+
+// ParsePage reads the before, after and limit query parameters.
+func ParsePage(r *http.Request) (Page, error) {
+	var page Page
+	q := r.URL.Query()
+
+	for name, dst := range map[string]*int64{"before": &page.Before, "after": &page.After} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				return page, fmt.Errorf("invalid %s cursor %q", name, v)
+			}
+			*dst = n
+		}
+	}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return page, fmt.Errorf("invalid limit %q", v)
+		}
+		page.Limit = n
+	}
+
+	return page.Normalize(), nil
+}
+
+// SendMessage godoc
+// @Summary      Send a message to the general chat
+// @Description  Stores a message in the shared general chat and delivers it to everyone connected to the "general" room over WebSocket. With parentId the message is a reply in the thread of that message and is delivered as a "thread.reply" event. Users mentioned with @username, or everyone in the room with @room, also get a "mention" event. Banned and muted users can't post, and nobody can while the room is archived.
+// @Tags         message
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        message  body      MessageReq  true  "Message request body"
+// @Success      201      {object}  Message
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      403      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      409      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Router       /messages [post]
+func (h *Handler) SendMessage(w http.ResponseWriter, r *http.Request) {
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		h.sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req MessageReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendErrorResponse(w, "Invalid request to send a message", http.StatusBadRequest)
+		return
+	}
+
+	m, err := h.Service.SendMessage(r.Context(), p.ID, p.Username, p.Admin, &req)
+	switch {
+	case errors.Is(err, ErrEmptyContent):
+		h.sendErrorResponse(w, "Message content is required", http.StatusBadRequest)
+		return
+	case errors.Is(err, ErrContentTooLong):
+		h.sendErrorResponse(w, fmt.Sprintf("Message is longer than %d characters", MaxContentLength), http.StatusBadRequest)
+		return
+	case errors.Is(err, ErrMuted):
+		h.sendErrorResponse(w, "You are muted in this room", http.StatusForbidden)
+		return
+	case errors.Is(err, ErrParentNotFound):
+		h.sendErrorResponse(w, "Parent message not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrRoomArchived):
+		h.sendErrorResponse(w, "The room is archived", http.StatusConflict)
+		return
+	case h.sendAccessError(w, err):
+		return
+	case err != nil:
+		h.Logger.Error("Failed to send message", "user_id", p.ID, "error", err)
+		h.sendErrorResponse(w, "Couldn't send the message", http.StatusInternalServerError)
+		return
+	}
+
+	h.sendSuccessResponse(w, m, "Message sent", http.StatusCreated)
+}
+
+// GetMessages godoc
+// @Summary      Get general chat messages
+// @Description  Returns messages of the general chat ordered from oldest to newest, without the replies in threads. Pass the id of the first returned message as "before" to scroll back, or the id of the last one as "after" to catch up. Users banned from the room can't read it.
+// @Tags         message
+// @Produce      json
+// @Security     BearerAuth
+// @Param        before  query     int  false  "Return messages with id lower than this"
+// @Param        after   query     int  false  "Return messages with id greater than this"
+// @Param        limit   query     int  false  "Page size, 50 by default and 100 at most"
+// @Success      200     {object}  MessagesRes
+// @Failure      400     {object}  ErrorResponse
+// @Failure      401     {object}  ErrorResponse
+// @Failure      403     {object}  ErrorResponse
+// @Failure      500     {object}  ErrorResponse
+// @Router       /messages [get]
+func (h *Handler) GetMessages(w http.ResponseWriter, r *http.Request) {
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		h.sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	page, err := ParsePage(r)
+	if err != nil {
+		h.sendErrorResponse(w, "Invalid pagination parameters", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.Service.GetMessages(r.Context(), p.ID, p.Admin, page)
+	if h.sendAccessError(w, err) {
+		return
+	}
+	if err != nil {
+		h.Logger.Error("Failed to load messages", "error", err)
+		h.sendErrorResponse(w, "Couldn't load messages", http.StatusInternalServerError)
+		return
+	}
+
+	h.sendSuccessResponse(w, res, "Messages loaded", http.StatusOK)
+}
+
+// sendAccessError answers the request if err says the caller can't read the
+// room.
+func (h *Handler) sendAccessError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, ErrBanned):
+		h.sendErrorResponse(w, "You are banned from this room", http.StatusForbidden)
+	case errors.Is(err, ErrNoAccess):
+		h.sendErrorResponse(w, "You can't access this room", http.StatusForbidden)
+	default:
+		return false
+	}
+	return true
+}
+
+// GetMentions godoc
+// @Summary      Get my mentions
+// @Description  Returns the messages mentioning the caller, by name or with @room in a room they are a member of, ordered from oldest to newest and paged like the messages of a room. Deleted messages and messages of rooms the caller can't read are left out.
+// @Tags         user
+// @Produce      json
+// @Security     BearerAuth
+// @Param        before  query     int  false  "Return messages with id lower than this"
+// @Param        after   query     int  false  "Return messages with id greater than this"
+// @Param        limit   query     int  false  "Page size, 50 by default and 100 at most"
+// @Success      200     {object}  MessagesRes
+// @Failure      400     {object}  ErrorResponse
+// @Failure      401     {object}  ErrorResponse
+// @Failure      500     {object}  ErrorResponse
+// @Router       /users/me/mentions [get]
+func (h *Handler) GetMentions(w http.ResponseWriter, r *http.Request) {
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		h.sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	page, err := ParsePage(r)
+	if err != nil {
+		h.sendErrorResponse(w, "Invalid pagination parameters", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.Service.GetMentions(r.Context(), p.ID, page)
+	if err != nil {
+		h.Logger.Error("Failed to load mentions", "user_id", p.ID, "error", err)
+		h.sendErrorResponse(w, "Couldn't load mentions", http.StatusInternalServerError)
+		return
+	}
+
+	h.sendSuccessResponse(w, res, "Mentions loaded", http.StatusOK)
+}
+
+func parseUserID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid user id %q", chi.URLParam(r, "id"))
+	}
+	return id, nil
+}
+
+// SendDirectMessage godoc
+// @Summary      Send a direct message
+// @Description  Stores a private message to the user and delivers it to all of their open WebSocket connections.
+// @Tags         message
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      int         true  "Recipient user ID"
+// @Param        message  body      MessageReq  true  "Message request body"
+// @Success      201      {object}  DirectMessage
+// @Failure      400      {object}  ErrorResponse
+// @Failure      401      {object}  ErrorResponse
+// @Failure      404      {object}  ErrorResponse
+// @Failure      500      {object}  ErrorResponse
+// @Router       /users/{id}/messages [post]
+func (h *Handler) SendDirectMessage(w http.ResponseWriter, r *http.Request) {
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		h.sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	recipientID, err := parseUserID(r)
+	if err != nil {
+		h.sendErrorResponse(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	var req MessageReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendErrorResponse(w, "Invalid request to send a message", http.StatusBadRequest)
+		return
+	}
+
+	dm, err := h.Service.SendDirectMessage(r.Context(), p.ID, p.Username, recipientID, &req)
+	switch {
+	case errors.Is(err, ErrEmptyContent):
+		h.sendErrorResponse(w, "Message content is required", http.StatusBadRequest)
+		return
+	case errors.Is(err, ErrContentTooLong):
+		h.sendErrorResponse(w, fmt.Sprintf("Message is longer than %d characters", MaxContentLength), http.StatusBadRequest)
+		return
+	case errors.Is(err, ErrSelfMessage):
+		h.sendErrorResponse(w, "Can't send a direct message to yourself", http.StatusBadRequest)
+		return
+	case errors.Is(err, ErrRecipientNotFound):
+		h.sendErrorResponse(w, "User not found", http.StatusNotFound)
+		return
+	case err != nil:
+		h.Logger.Error("Failed to send direct message", "user_id", p.ID, "recipient_id", recipientID, "error", err)
+		h.sendErrorResponse(w, "Couldn't send the message", http.StatusInternalServerError)
+		return
+	}
+
+	h.sendSuccessResponse(w, dm, "Direct message sent", http.StatusCreated)
+}
+
+// GetConversations godoc
+// @Summary      List direct message conversations
+// @Description  Returns the users the caller exchanged direct messages with, newest conversation first, with the last message, the number of unread messages and how far each side has read.
+// @Tags         message
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  ConversationsRes
+// @Failure      401  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /users/messages [get]
+func (h *Handler) GetConversations(w http.ResponseWriter, r *http.Request) {
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		h.sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	res, err := h.Service.GetConversations(r.Context(), p.ID)
+	if err != nil {
+		h.Logger.Error("Failed to load conversations", "user_id", p.ID, "error", err)
+		h.sendErrorResponse(w, "Couldn't load conversations", http.StatusInternalServerError)
+		return
+	}
+
+	h.sendSuccessResponse(w, res, "Conversations loaded", http.StatusOK)
+}
+
+// GetConversation godoc
+// @Summary      Get a direct message conversation
+// @Description  Returns messages exchanged with the user ordered from oldest to newest and marks the received ones as read, sending them a "read" receipt. Paginate with "before" and "after" like GET /messages.
+// @Tags         message
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      int  true   "Other user ID"
+// @Param        before  query     int  false  "Return messages with id lower than this"
+// @Param        after   query     int  false  "Return messages with id greater than this"
+// @Param        limit   query     int  false  "Page size, 50 by default and 100 at most"
+// @Success      200     {object}  DirectMessagesRes
+// @Failure      400     {object}  ErrorResponse
+// @Failure      401     {object}  ErrorResponse
+// @Failure      500     {object}  ErrorResponse
+// @Router       /users/{id}/messages [get]
+func (h *Handler) GetConversation(w http.ResponseWriter, r *http.Request) {
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		h.sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	peerID, err := parseUserID(r)
+	if err != nil {
+		h.sendErrorResponse(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	page, err := ParsePage(r)
+	if err != nil {
+		h.sendErrorResponse(w, "Invalid pagination parameters", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.Service.GetConversation(r.Context(), p.ID, p.Username, peerID, page)
+	if err != nil {
+		h.Logger.Error("Failed to load conversation", "user_id", p.ID, "peer_id", peerID, "error", err)
+		h.sendErrorResponse(w, "Couldn't load the conversation", http.StatusInternalServerError)
+		return
+	}
+
+	h.sendSuccessResponse(w, res, "Conversation loaded", http.StatusOK)
+}
+
+// MarkConversationRead godoc
+// @Summary      Mark a direct message conversation as read
+// @Description  Marks the messages received from the user as read up to messageId, or all of them without one, and sends them a "read" receipt over WebSocket. Answers with the receipt, or 204 when there was nothing left to read.
+// @Tags         message
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id    path      int      true   "Other user ID"
+// @Param        read  body      ReadReq  false  "Last message read"
+// @Success      200   {object}  ReadReceipt
+// @Success      204   {string}  string  "No Content"
+// @Failure      400   {object}  ErrorResponse
+// @Failure      401   {object}  ErrorResponse
+// @Failure      500   {object}  ErrorResponse
+// @Router       /users/{id}/messages/read [post]
+func (h *Handler) MarkConversationRead(w http.ResponseWriter, r *http.Request) {
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		h.sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	peerID, err := parseUserID(r)
+	if err != nil {
+		h.sendErrorResponse(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	var req ReadReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	receipt, err := h.Service.MarkConversationRead(r.Context(), p.ID, p.Username, peerID, &req)
+	switch {
+	case errors.Is(err, ErrMessageNotFound):
+		h.sendErrorResponse(w, "Invalid message id", http.StatusBadRequest)
+		return
+	case err != nil:
+		h.Logger.Error("Failed to mark conversation read", "user_id", p.ID, "peer_id", peerID, "error", err)
+		h.sendErrorResponse(w, "Couldn't mark the conversation as read", http.StatusInternalServerError)
+		return
+	}
+
+	if receipt == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	h.sendSuccessResponse(w, receipt, "Conversation read", http.StatusOK)
+}
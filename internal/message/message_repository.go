@@ -0,0 +1,581 @@
+package message
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"strings"
+)
+
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(context.Context, string) (*sql.Stmt, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+type repository struct {
+	db DBTX
+}
+
+func NewRepository(db DBTX) Repository {
+	return &repository{db: db}
+}
+
+// pageClause adds the cursor conditions of page to conds and returns the
+// WHERE ... LIMIT tail of a query paging over idCol. It walks forward from an
+// "after" cursor, otherwise back from the newest row (or the "before" cursor),
+// and asks for one extra row to tell whether there is more.
+func pageClause(idCol string, conds []string, args []interface{}, page Page) (string, []interface{}, bool) {
+	if page.Before > 0 {
+		args = append(args, page.Before)
+		conds = append(conds, fmt.Sprintf("%s < $%d", idCol, len(args)))
+	}
+	if page.After > 0 {
+		args = append(args, page.After)
+		conds = append(conds, fmt.Sprintf("%s > $%d", idCol, len(args)))
+	}
+
+	desc := page.After == 0 || page.Before > 0
+	order := "ASC"
+	if desc {
+		order = "DESC"
+	}
+	args = append(args, page.Limit+1)
+
+	tail := fmt.Sprintf("WHERE %s ORDER BY %s %s LIMIT $%d", strings.Join(conds, " AND "), idCol, order, len(args))
+	return tail, args, desc
+}
+
+// trimPage drops the extra row fetched by pageClause and puts the rows in
+// ascending order.
+func trimPage[T any](rows []T, limit int, desc bool) ([]T, bool) {
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+	if desc {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+	return rows, hasMore
+}
+
+// messageColumns are the columns scanned by Message.fields, in order, for a
+// messages table aliased m joined with its author aliased u.
+const messageColumns = `m.id, m.room_id, m.user_id, u.username, m.content, m.parent_id,
+	(SELECT count(*) FROM messages r WHERE r.parent_id = m.id AND r.deleted_at IS NULL),
+	` + reactionsColumn + `,
+	` + mentionsColumn + `,
+	m.created_at, m.edited_at, m.deleted_at`
+
+// reactionsColumn aggregates the reactions to the message m into the JSON
+// read by Reactions.Scan, NULL when there are none.
+const reactionsColumn = `(SELECT json_agg(json_build_object(
+		'emoji', g.emoji, 'count', g.count, 'userIds', g.user_ids, 'usernames', g.usernames
+	) ORDER BY g.first)
+	FROM (
+		SELECT x.emoji, count(*) AS count, min(x.created_at) AS first,
+			array_agg(x.user_id ORDER BY x.created_at) AS user_ids,
+			array_agg(xu.username ORDER BY x.created_at) AS usernames
+		FROM message_reactions x JOIN users xu ON xu.id = x.user_id
+		WHERE x.message_id = m.id
+		GROUP BY x.emoji
+	) g)`
+
+// mentionsColumn aggregates the mentions in the message m into the JSON read
+// by Mentions.Scan, NULL when there are none or the message was deleted.
+const mentionsColumn = `(SELECT json_agg(` + mentionObject + ` ORDER BY x.start)
+	FROM message_mentions x LEFT JOIN users xu ON xu.id = x.user_id
+	WHERE x.message_id = m.id AND m.deleted_at IS NULL)`
+
+// mentionObject builds a Mention from a message_mentions row aliased x and
+// the mentioned user aliased xu.
+const mentionObject = `json_build_object(
+		'kind', x.kind, 'userId', x.user_id, 'username', xu.username, 'offset', x.start, 'length', x.length
+	)`
+
+// insertMentions is a statement resolving the mentions passed as the arrays
+// $n to $n+3 (see mentionArgs) and storing them for the message in the
+// relation from. A name mentions the user carrying it, ignoring case, which
+// usernames are unique by.
+func insertMentions(from string, n int) string {
+	return fmt.Sprintf(`INSERT INTO message_mentions (message_id, kind, user_id, start, length)
+		SELECT src.id, x.kind, mu.id, x.start, x.length
+		FROM %s src
+		CROSS JOIN unnest($%d::varchar[], $%d::varchar[], $%d::int[], $%d::int[]) AS x (kind, username, start, length)
+		LEFT JOIN users mu ON x.kind = 'user' AND mu.username <> '' AND lower(mu.username) = lower(x.username)
+		WHERE x.kind = 'room' OR mu.id IS NOT NULL
+		RETURNING kind, user_id, start, length`, from, n, n+1, n+2, n+3)
+}
+
+// mentionArgs turns the mentions parsed from content into the arguments of
+// insertMentions.
+func mentionArgs(content string) []interface{} {
+	mentions := ParseMentions(content)
+
+	kinds := make([]string, 0, len(mentions))
+	names := make([]string, 0, len(mentions))
+	starts := make([]int64, 0, len(mentions))
+	lengths := make([]int64, 0, len(mentions))
+	for _, m := range mentions {
+		kinds = append(kinds, string(m.Kind))
+		names = append(names, m.Username)
+		starts = append(starts, int64(m.Offset))
+		lengths = append(lengths, int64(m.Length))
+	}
+
+	return []interface{}{pq.Array(kinds), pq.Array(names), pq.Array(starts), pq.Array(lengths)}
+}
+
+// readableBy is a condition on the message m telling whether the user may
+// read its room, the same way ws.Hub.CanRead does: they need access to the
+// room and can't be banned from it, unless they own it or are an admin.
+func readableBy(userID string) string {
+	return fmt.Sprintf(`EXISTS (
+		SELECT 1 FROM rooms r JOIN users ru ON ru.id = %[1]s
+		WHERE r.id = m.room_id AND (r.visibility = 'public' OR ru.is_admin OR r.created_by = ru.id
+			OR EXISTS (SELECT 1 FROM room_members rm WHERE rm.room_id = r.id AND rm.user_id = ru.id))
+		AND (ru.is_admin OR r.created_by = ru.id OR NOT EXISTS (
+			SELECT 1 FROM room_restrictions x WHERE x.room_id = r.id AND x.user_id = ru.id AND x.kind = 'ban'
+				AND (x.expires_at IS NULL OR x.expires_at > now())))
+	)`, userID)
+}
+
+func (m *Message) fields() []interface{} {
+	return []interface{}{&m.ID, &m.RoomID, &m.UserID, &m.Username, &m.Content, &m.ParentID, &m.ReplyCount, &m.Reactions, &m.Mentions, &m.CreatedAt, &m.EditedAt, &m.DeletedAt}
+}
+
+// scanAggregate reads a JSON aggregate into dst, leaving it nil for NULL.
+func scanAggregate(name string, src, dst interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dst)
+	case string:
+		return json.Unmarshal([]byte(v), dst)
+	}
+	return fmt.Errorf("message.%s: can't scan %T", name, src)
+}
+
+// Scan implements sql.Scanner for the aggregate of reactionsColumn.
+func (r *Reactions) Scan(src interface{}) error {
+	*r = nil
+	return scanAggregate("Reactions", src, r)
+}
+
+// Scan implements sql.Scanner for the aggregate of mentionsColumn.
+func (m *Mentions) Scan(src interface{}) error {
+	*m = nil
+	return scanAggregate("Mentions", src, m)
+}
+
+func (r *repository) CreateMessage(ctx context.Context, m *Message) (*Message, error) {
+	const op = "message.Repository.CreateMessage"
+
+	// the parent has to be a live message of the same room
+	query := `WITH parent AS (
+			SELECT COALESCE(parent_id, id) AS id FROM messages WHERE id = $4 AND room_id = $1 AND deleted_at IS NULL
+		), created AS (
+			INSERT INTO messages (room_id, user_id, content, parent_id)
+			SELECT $1, $2::bigint, $3, (SELECT id FROM parent)
+			WHERE $4::bigint IS NULL OR EXISTS (SELECT 1 FROM parent)
+			RETURNING id, parent_id, created_at
+		), mentioned AS (
+			` + insertMentions("created", 5) + `
+		)
+		SELECT c.id, c.parent_id, c.created_at,
+			(SELECT json_agg(` + mentionObject + ` ORDER BY x.start) FROM mentioned x LEFT JOIN users xu ON xu.id = x.user_id)
+		FROM created c`
+	args := append([]interface{}{m.RoomID, m.UserID, m.Content, m.ParentID}, mentionArgs(m.Content)...)
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&m.ID, &m.ParentID, &m.CreatedAt, &m.Mentions)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrParentNotFound, op)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return m, nil
+}
+
+func (r *repository) ListRoomMessages(ctx context.Context, roomID string, page Page) ([]*Message, bool, error) {
+	const op = "message.Repository.ListRoomMessages"
+
+	messages, hasMore, err := r.listMessages(ctx, []string{"m.room_id = $1", "m.parent_id IS NULL"}, roomID, page)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return messages, hasMore, nil
+}
+
+func (r *repository) ListRoomMessagesAfter(ctx context.Context, roomID string, after int64, limit int) ([]*Message, bool, error) {
+	const op = "message.Repository.ListRoomMessagesAfter"
+
+	messages, hasMore, err := r.listMessages(ctx, []string{"m.room_id = $1"}, roomID, Page{After: after, Limit: limit})
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return messages, hasMore, nil
+}
+
+func (r *repository) ListReplies(ctx context.Context, parentID int64, page Page) ([]*Message, bool, error) {
+	const op = "message.Repository.ListReplies"
+
+	messages, hasMore, err := r.listMessages(ctx, []string{"m.parent_id = $1"}, parentID, page)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return messages, hasMore, nil
+}
+
+// listMessages returns a page of the messages matching conds, which take arg
+// as $1.
+func (r *repository) listMessages(ctx context.Context, conds []string, arg interface{}, page Page) ([]*Message, bool, error) {
+	page = page.Normalize()
+
+	tail, args, desc := pageClause("m.id", conds, []interface{}{arg}, page)
+	query := `SELECT ` + messageColumns + `
+		FROM messages m JOIN users u ON u.id = m.user_id ` + tail
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	messages := make([]*Message, 0, page.Limit)
+	for rows.Next() {
+		m := Message{}
+		if err := rows.Scan(m.fields()...); err != nil {
+			return nil, false, err
+		}
+		messages = append(messages, &m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	messages, hasMore := trimPage(messages, page.Limit, desc)
+	return messages, hasMore, nil
+}
+
+func (r *repository) GetMessage(ctx context.Context, id int64) (*Message, error) {
+	const op = "message.Repository.GetMessage"
+	m := Message{}
+
+	query := `SELECT ` + messageColumns + ` FROM messages m JOIN users u ON u.id = m.user_id WHERE m.id = $1`
+	err := r.db.QueryRowContext(ctx, query, id).Scan(m.fields()...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, op)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return &m, nil
+}
+
+func (r *repository) UpdateMessage(ctx context.Context, id, editorID int64, content string) (*Message, error) {
+	const op = "message.Repository.UpdateMessage"
+
+	m, err := r.rewrite(ctx, op, id, editorID, "content = $3, edited_at = now()", content)
+	if err != nil {
+		return nil, err
+	}
+
+	// the statement doesn't see the mentions it stored, so they're read back
+	query := `SELECT ` + mentionsColumn + ` FROM messages m WHERE m.id = $1`
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&m.Mentions); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return m, nil
+}
+
+func (r *repository) DeleteMessage(ctx context.Context, id, deletedBy int64) (*Message, error) {
+	const op = "message.Repository.DeleteMessage"
+
+	return r.rewrite(ctx, op, id, deletedBy, "content = '', deleted_at = now(), deleted_by = $2", nil)
+}
+
+// rewrite saves the current content of a live message to its history and
+// applies set to it, in one statement. A new content replaces the mentions of
+// the message, a deleted message keeps them hidden.
+func (r *repository) rewrite(ctx context.Context, op string, id, by int64, set string, content interface{}) (*Message, error) {
+	m := Message{}
+
+	args := []interface{}{id, by}
+	mentions := ""
+	if content != nil {
+		args = append(args, content)
+		args = append(args, mentionArgs(content.(string))...)
+		mentions = `, dropped AS (
+			DELETE FROM message_mentions WHERE message_id = (SELECT id FROM prev)
+		), mentioned AS (
+			` + insertMentions("prev", 4) + `
+		)`
+	}
+
+	query := `WITH prev AS (
+			SELECT id, content FROM messages WHERE id = $1 AND deleted_at IS NULL FOR UPDATE
+		), saved AS (
+			INSERT INTO message_edits (message_id, content, edited_by) SELECT id, content, $2 FROM prev
+		)` + mentions + `, m AS (
+			UPDATE messages SET ` + set + ` WHERE id = (SELECT id FROM prev)
+			RETURNING *
+		)
+		SELECT ` + messageColumns + ` FROM m JOIN users u ON u.id = m.user_id`
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(m.fields()...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, op)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return &m, nil
+}
+
+func (r *repository) ListEdits(ctx context.Context, messageID int64) ([]*Edit, error) {
+	const op = "message.Repository.ListEdits"
+
+	query := `SELECT e.id, e.message_id, e.content, e.edited_by, u.username, e.edited_at
+		FROM message_edits e JOIN users u ON u.id = e.edited_by
+		WHERE e.message_id = $1
+		ORDER BY e.id`
+	rows, err := r.db.QueryContext(ctx, query, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+	defer rows.Close()
+
+	edits := make([]*Edit, 0)
+	for rows.Next() {
+		e := Edit{}
+		if err := rows.Scan(&e.ID, &e.MessageID, &e.Content, &e.EditedBy, &e.EditorName, &e.EditedAt); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, op)
+		}
+		edits = append(edits, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return edits, nil
+}
+
+func (r *repository) AddReaction(ctx context.Context, messageID, userID int64, emoji string) error {
+	const op = "message.Repository.AddReaction"
+
+	query := "INSERT INTO message_reactions (message_id, user_id, emoji) VALUES ($1, $2, $3)"
+	if _, err := r.db.ExecContext(ctx, query, messageID, userID, emoji); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case "23505":
+				return fmt.Errorf("%w: %s", ErrReactionExists, op)
+			case "23503":
+				return fmt.Errorf("%w: %s", ErrMessageNotFound, op)
+			}
+		}
+		return fmt.Errorf("%w: %s", err, op)
+	}
+
+	return nil
+}
+
+func (r *repository) RemoveReaction(ctx context.Context, messageID, userID int64, emoji string) error {
+	const op = "message.Repository.RemoveReaction"
+
+	query := "DELETE FROM message_reactions WHERE message_id = $1 AND user_id = $2 AND emoji = $3"
+	res, err := r.db.ExecContext(ctx, query, messageID, userID, emoji)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, op)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, op)
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %s", ErrReactionNotFound, op)
+	}
+
+	return nil
+}
+
+func (r *repository) MentionedUsers(ctx context.Context, messageID int64) ([]int64, error) {
+	const op = "message.Repository.MentionedUsers"
+
+	query := `SELECT n.user_id
+		FROM (
+			SELECT x.user_id FROM message_mentions x WHERE x.message_id = $1 AND x.kind = 'user'
+			UNION
+			SELECT rm.user_id FROM message_mentions x
+				JOIN messages msg ON msg.id = x.message_id
+				JOIN room_members rm ON rm.room_id = msg.room_id
+			WHERE x.message_id = $1 AND x.kind = 'room'
+		) n
+		JOIN messages m ON m.id = $1 AND m.deleted_at IS NULL AND m.user_id <> n.user_id
+		WHERE ` + readableBy("n.user_id")
+	rows, err := r.db.QueryContext(ctx, query, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+	defer rows.Close()
+
+	userIDs := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, op)
+		}
+		userIDs = append(userIDs, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return userIDs, nil
+}
+
+func (r *repository) ListMentions(ctx context.Context, userID int64, page Page) ([]*Message, bool, error) {
+	const op = "message.Repository.ListMentions"
+
+	// @room mentions the members of the room only, like MentionedUsers
+	conds := []string{
+		"m.deleted_at IS NULL",
+		"m.user_id <> $1",
+		`EXISTS (SELECT 1 FROM message_mentions x WHERE x.message_id = m.id AND (x.user_id = $1
+			OR (x.kind = 'room' AND EXISTS (SELECT 1 FROM room_members rm WHERE rm.room_id = m.room_id AND rm.user_id = $1))))`,
+		readableBy("$1"),
+	}
+	messages, hasMore, err := r.listMessages(ctx, conds, userID, page)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return messages, hasMore, nil
+}
+
+func (r *repository) CreateDirectMessage(ctx context.Context, dm *DirectMessage) (*DirectMessage, error) {
+	const op = "message.Repository.CreateDirectMessage"
+
+	query := "INSERT INTO direct_messages (sender_id, recipient_id, content) VALUES ($1, $2, $3) RETURNING id, created_at"
+	err := r.db.QueryRowContext(ctx, query, dm.SenderID, dm.RecipientID, dm.Content).Scan(&dm.ID, &dm.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return nil, fmt.Errorf("%w: %s", ErrRecipientNotFound, op)
+		}
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return dm, nil
+}
+
+func (r *repository) ListDirectMessages(ctx context.Context, userID, peerID int64, page Page) ([]*DirectMessage, bool, error) {
+	const op = "message.Repository.ListDirectMessages"
+	page = page.Normalize()
+
+	tail, args, desc := pageClause("d.id",
+		[]string{"((d.sender_id = $1 AND d.recipient_id = $2) OR (d.sender_id = $2 AND d.recipient_id = $1))"},
+		[]interface{}{userID, peerID}, page)
+	query := `SELECT d.id, d.sender_id, u.username, d.recipient_id, d.content, d.created_at, d.read_at
+		FROM direct_messages d JOIN users u ON u.id = d.sender_id ` + tail
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %s", err, op)
+	}
+	defer rows.Close()
+
+	messages := make([]*DirectMessage, 0, page.Limit)
+	for rows.Next() {
+		dm := DirectMessage{}
+		if err := rows.Scan(&dm.ID, &dm.SenderID, &dm.SenderName, &dm.RecipientID, &dm.Content, &dm.CreatedAt, &dm.ReadAt); err != nil {
+			return nil, false, fmt.Errorf("%w: %s", err, op)
+		}
+		messages = append(messages, &dm)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("%w: %s", err, op)
+	}
+
+	messages, hasMore := trimPage(messages, page.Limit, desc)
+	return messages, hasMore, nil
+}
+
+func (r *repository) ListConversations(ctx context.Context, userID int64) ([]*Conversation, error) {
+	const op = "message.Repository.ListConversations"
+
+	query := `SELECT last.peer_id, p.username,
+			last.id, last.sender_id, s.username, last.recipient_id, last.content, last.created_at, last.read_at,
+			(SELECT count(*) FROM direct_messages d
+				WHERE d.sender_id = last.peer_id AND d.recipient_id = $1 AND d.read_at IS NULL),
+			(SELECT COALESCE(max(d.id), 0) FROM direct_messages d
+				WHERE d.sender_id = last.peer_id AND d.recipient_id = $1 AND d.read_at IS NOT NULL),
+			(SELECT COALESCE(max(d.id), 0) FROM direct_messages d
+				WHERE d.sender_id = $1 AND d.recipient_id = last.peer_id AND d.read_at IS NOT NULL)
+		FROM (
+			SELECT DISTINCT ON (peer_id) *
+			FROM (
+				SELECT *, CASE WHEN sender_id = $1 THEN recipient_id ELSE sender_id END AS peer_id
+				FROM direct_messages
+				WHERE sender_id = $1 OR recipient_id = $1
+			) mine
+			ORDER BY peer_id, id DESC
+		) last
+		JOIN users p ON p.id = last.peer_id
+		JOIN users s ON s.id = last.sender_id
+		ORDER BY last.id DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+	defer rows.Close()
+
+	conversations := make([]*Conversation, 0)
+	for rows.Next() {
+		c := Conversation{LastMessage: &DirectMessage{}}
+		dm := c.LastMessage
+		err := rows.Scan(&c.UserID, &c.Username,
+			&dm.ID, &dm.SenderID, &dm.SenderName, &dm.RecipientID, &dm.Content, &dm.CreatedAt, &dm.ReadAt,
+			&c.UnreadCount, &c.LastReadID, &c.PeerLastReadID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, op)
+		}
+		conversations = append(conversations, &c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return conversations, nil
+}
+
+func (r *repository) MarkConversationRead(ctx context.Context, userID, peerID, upTo int64) (int64, error) {
+	const op = "message.Repository.MarkConversationRead"
+
+	var lastReadID int64
+	query := `WITH marked AS (
+			UPDATE direct_messages SET read_at = now()
+			WHERE recipient_id = $1 AND sender_id = $2 AND read_at IS NULL AND ($3 = 0 OR id <= $3)
+			RETURNING id
+		)
+		SELECT COALESCE(max(id), 0) FROM marked`
+	if err := r.db.QueryRowContext(ctx, query, userID, peerID, upTo).Scan(&lastReadID); err != nil {
+		return 0, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return lastReadID, nil
+}
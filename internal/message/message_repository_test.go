@@ -0,0 +1,47 @@
+package message
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestReadableBy(t *testing.T) {
+	tests := []struct {
+		name string
+		// want is the part of the condition that enforces the rule
+		want string
+	}{
+		{
+			name: "public room",
+			want: "r.visibility = 'public'",
+		},
+		{
+			name: "member of a private room",
+			want: "EXISTS (SELECT 1 FROM room_members rm WHERE rm.room_id = r.id AND rm.user_id = ru.id)",
+		},
+		{
+			name: "banned user",
+			want: "NOT EXISTS ( SELECT 1 FROM room_restrictions x WHERE x.room_id = r.id AND x.user_id = ru.id AND x.kind = 'ban' " +
+				"AND (x.expires_at IS NULL OR x.expires_at > now()))",
+		},
+		{
+			name: "owners and admins aren't banned",
+			want: "AND (ru.is_admin OR r.created_by = ru.id OR NOT EXISTS (",
+		},
+	}
+
+	space := regexp.MustCompile(`\s+`)
+	cond := space.ReplaceAllString(readableBy("$1"), " ")
+	if !strings.Contains(cond, "JOIN users ru ON ru.id = $1") {
+		t.Fatalf("readableBy($1) doesn't check user $1: %s", cond)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(cond, tt.want) {
+				t.Fatalf("readableBy($1) = %s\nwant it to contain %s", cond, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package message
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+type service struct {
+	Repository
+	publisher Publisher
+	access    AccessChecker
+	timeout   time.Duration
+}
+
+func NewService(r Repository, p Publisher, a AccessChecker) Service {
+	return &service{
+		Repository: r,
+		publisher:  p,
+		access:     a,
+		timeout:    10 * time.Second,
+	}
+}
+
+// ValidateContent checks a message body before it is stored.
+func ValidateContent(content string) error {
+	if strings.TrimSpace(content) == "" {
+		return ErrEmptyContent
+	}
+	if utf8.RuneCountInString(content) > MaxContentLength {
+		return ErrContentTooLong
+	}
+	return nil
+}
+
+// ValidateEmoji checks a reaction before it is stored. Any short text without
+// spaces is accepted, so that custom :names: work next to unicode emoji.
+func ValidateEmoji(emoji string) error {
+	if emoji == "" || utf8.RuneCountInString(emoji) > MaxEmojiLength || strings.ContainsFunc(emoji, unicode.IsSpace) {
+		return ErrInvalidEmoji
+	}
+	return nil
+}
+
+// ParseMentions finds the @username and @room mentions in content, leaving
+// them unresolved. A mention starts the content or follows a character that
+// can't be part of a name, so e-mail addresses mention nobody, and dots or
+// dashes ending it are taken as punctuation.
+func ParseMentions(content string) Mentions {
+	var mentions Mentions
+
+	runes := []rune(content)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '@' || (i > 0 && isNameRune(runes[i-1])) {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && isNameRune(runes[end]) {
+			end++
+		}
+		for end > i+1 && (runes[end-1] == '.' || runes[end-1] == '-') {
+			end--
+		}
+		if end == i+1 {
+			continue
+		}
+
+		m := &Mention{Kind: MentionUser, Username: string(runes[i+1 : end]), Offset: i, Length: end - i}
+		if m.Username == RoomMention {
+			m.Kind, m.Username = MentionRoom, ""
+		}
+		mentions = append(mentions, m)
+		i = end - 1
+	}
+
+	return mentions
+}
+
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-'
+}
+
+func (s *service) SendMessage(c context.Context, userID int64, username string, admin bool, req *MessageReq) (*Message, error) {
+	const op = "message.SendMessage"
+
+	if err := ValidateContent(req.Content); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	if err := s.access.CanPost(ctx, GeneralRoomID, userID, admin); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	m := &Message{
+		RoomID:  GeneralRoomID,
+		UserID:  userID,
+		Content: req.Content,
+	}
+	if req.ParentID != 0 {
+		m.ParentID = &req.ParentID
+	}
+
+	m, err := s.Repository.CreateMessage(ctx, m)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	m.Username = username
+
+	s.publisher.Publish(m)
+
+	return m, nil
+}
+
+func (s *service) GetMessages(c context.Context, userID int64, admin bool, page Page) (*MessagesRes, error) {
+	const op = "message.GetMessages"
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	if err := s.access.CanRead(ctx, GeneralRoomID, userID, admin); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	messages, hasMore, err := s.Repository.ListRoomMessages(ctx, GeneralRoomID, page)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &MessagesRes{Messages: messages, HasMore: hasMore}, nil
+}
+
+func (s *service) GetMentions(c context.Context, userID int64, page Page) (*MessagesRes, error) {
+	const op = "message.GetMentions"
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	messages, hasMore, err := s.Repository.ListMentions(ctx, userID, page)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &MessagesRes{Messages: messages, HasMore: hasMore}, nil
+}
+
+func (s *service) SendDirectMessage(c context.Context, senderID int64, senderName string, recipientID int64, req *MessageReq) (*DirectMessage, error) {
+	const op = "message.SendDirectMessage"
+
+	if senderID == recipientID {
+		return nil, fmt.Errorf("%s: %w", op, ErrSelfMessage)
+	}
+	if err := ValidateContent(req.Content); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	dm, err := s.Repository.CreateDirectMessage(ctx, &DirectMessage{
+		SenderID:    senderID,
+		RecipientID: recipientID,
+		Content:     req.Content,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	dm.SenderName = senderName
+
+	s.publisher.PublishDirect(dm)
+
+	return dm, nil
+}
+
+func (s *service) GetConversations(c context.Context, userID int64) (*ConversationsRes, error) {
+	const op = "message.GetConversations"
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	conversations, err := s.Repository.ListConversations(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &ConversationsRes{Conversations: conversations}, nil
+}
+
+func (s *service) GetConversation(c context.Context, userID int64, username string, peerID int64, page Page) (*DirectMessagesRes, error) {
+	const op = "message.GetConversation"
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	messages, hasMore, err := s.Repository.ListDirectMessages(ctx, userID, peerID, page)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := s.markRead(ctx, userID, username, peerID, 0); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &DirectMessagesRes{Messages: messages, HasMore: hasMore}, nil
+}
+
+func (s *service) MarkConversationRead(c context.Context, userID int64, username string, peerID int64, req *ReadReq) (*ReadReceipt, error) {
+	const op = "message.MarkConversationRead"
+
+	if req.MessageID < 0 {
+		return nil, fmt.Errorf("%s: %w", op, ErrMessageNotFound)
+	}
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	receipt, err := s.markRead(ctx, userID, username, peerID, req.MessageID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return receipt, nil
+}
+
+// markRead marks received messages as read and lets the sender know.
+func (s *service) markRead(ctx context.Context, userID int64, username string, peerID, upTo int64) (*ReadReceipt, error) {
+	lastReadID, err := s.Repository.MarkConversationRead(ctx, userID, peerID, upTo)
+	if err != nil || lastReadID == 0 {
+		return nil, err
+	}
+
+	receipt := &ReadReceipt{
+		PeerID:     peerID,
+		UserID:     userID,
+		Username:   username,
+		LastReadID: lastReadID,
+		ReadAt:     time.Now(),
+	}
+	s.publisher.PublishReceipt(receipt)
+
+	return receipt, nil
+}
@@ -0,0 +1,87 @@
+package message
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    Mentions
+	}{
+		{
+			name:    "no mentions",
+			content: "hello there",
+		},
+		{
+			name:    "username",
+			content: "hi @bob",
+			want:    Mentions{{Kind: MentionUser, Username: "bob", Offset: 3, Length: 4}},
+		},
+		{
+			name:    "room",
+			content: "@room heads up",
+			want:    Mentions{{Kind: MentionRoom, Offset: 0, Length: 5}},
+		},
+		{
+			name:    "e-mail address",
+			content: "write to a@b.com",
+		},
+		{
+			name:    "trailing punctuation",
+			content: "thanks @bob.",
+			want:    Mentions{{Kind: MentionUser, Username: "bob", Offset: 7, Length: 4}},
+		},
+		{
+			name:    "trailing dashes and dots",
+			content: "@bob-.- ok",
+			want:    Mentions{{Kind: MentionUser, Username: "bob", Offset: 0, Length: 4}},
+		},
+		{
+			name:    "dots inside a name",
+			content: "@bob.smith",
+			want:    Mentions{{Kind: MentionUser, Username: "bob.smith", Offset: 0, Length: 10}},
+		},
+		{
+			name:    "double at",
+			content: "@@x",
+			want:    Mentions{{Kind: MentionUser, Username: "x", Offset: 1, Length: 2}},
+		},
+		{
+			name:    "lone at",
+			content: "meet @ noon",
+		},
+		{
+			name:    "offsets count characters",
+			content: "привет @ёжик, @room",
+			want: Mentions{
+				{Kind: MentionUser, Username: "ёжик", Offset: 7, Length: 5},
+				{Kind: MentionRoom, Offset: 14, Length: 5},
+			},
+		},
+		{
+			name:    "inside parentheses",
+			content: "(@bob)",
+			want:    Mentions{{Kind: MentionUser, Username: "bob", Offset: 1, Length: 4}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseMentions(tt.content)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseMentions(%q) = %v, want %v", tt.content, mentionValues(got), mentionValues(tt.want))
+			}
+		})
+	}
+}
+
+func mentionValues(mentions Mentions) []Mention {
+	out := make([]Mention, 0, len(mentions))
+	for _, m := range mentions {
+		out = append(out, *m)
+	}
+	return out
+}
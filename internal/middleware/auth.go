@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var (
+	ErrMissingToken = errors.New("missing token")
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Principal is the authenticated caller extracted from a verified JWT.
+type Principal struct {
+	ID       int64  `json:"uid"`
+	Username string `json:"uname"`
+	Email    string `json:"uemail"`
+	// Admin may manage every room, not only the ones they own.
+	Admin bool `json:"uadmin"`
+}
+
+// AdminChecker looks up whether a user is an administrator right now.
+type AdminChecker interface {
+	IsAdmin(ctx context.Context, userID int64) (bool, error)
+}
+
+// VerifyAdmin checks an admin claim of p against admins. Tokens are valid for
+// a day, so a claim alone would keep revoked rights alive until the token
+// expires. The claim is dropped when the lookup fails.
+func VerifyAdmin(ctx context.Context, p *Principal, admins AdminChecker) error {
+	const op = "middleware.VerifyAdmin"
+
+	if !p.Admin {
+		return nil
+	}
+
+	admin, err := admins.IsAdmin(ctx, p.ID)
+	p.Admin = admin && err == nil
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Claims mirrors the payload issued by user.NewToken.
+type Claims struct {
+	Principal
+	jwt.RegisteredClaims
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the caller stored by AuthMiddleware.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// TokenFromRequest looks for a bearer token in the Authorization header first
+// and falls back to the "token" cookie set on login.
+func TokenFromRequest(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); h != "" {
+		scheme, token, ok := strings.Cut(h, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+
+	if c, err := r.Cookie("token"); err == nil {
+		return c.Value
+	}
+
+	return ""
+}
+
+func ParseToken(tokenString string) (*Principal, error) {
+	const op = "middleware.ParseToken"
+
+	if tokenString == "" {
+		return nil, fmt.Errorf("%s: %w", op, ErrMissingToken)
+	}
+
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(os.Getenv("SECRET")), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %v", op, ErrInvalidToken, err)
+	}
+
+	return &claims.Principal, nil
+}
+
+func Authenticate(r *http.Request) (*Principal, error) {
+	return ParseToken(TokenFromRequest(r))
+}
+
+// AuthMiddleware stores the caller in the request context. Admin claims are
+// verified with admins on every request.
+func AuthMiddleware(logger *slog.Logger, admins AdminChecker) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, err := Authenticate(r)
+			if err != nil {
+				logger.Warn("Unauthorized request",
+					slog.String("url", r.URL.String()),
+					slog.String("error", err.Error()))
+				SendUnauthorized(w, err)
+				return
+			}
+			if err := VerifyAdmin(r.Context(), p, admins); err != nil {
+				logger.Error("Couldn't verify admin rights",
+					slog.Int64("user_id", p.ID),
+					slog.String("error", err.Error()))
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+func SendUnauthorized(w http.ResponseWriter, err error) {
+	message := "Invalid token"
+	if errors.Is(err, ErrMissingToken) {
+		message = "Authentication required"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(errorResponse{Error: message})
+}
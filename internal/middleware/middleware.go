@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"encoding/json"
 	"log/slog"
 	"net/http"
 )
@@ -16,3 +17,19 @@ func LoggingMiddleware(logger *slog.Logger) func(next http.Handler) http.Handler
 		})
 	}
 }
+
+// NotFound answers unknown routes with the same JSON error body as the
+// handlers.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	sendError(w, "Not found", http.StatusNotFound)
+}
+
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+func sendError(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(errorResponse{Error: message})
+}
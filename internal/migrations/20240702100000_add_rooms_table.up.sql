@@ -0,0 +1,7 @@
+CREATE TABLE rooms (
+    id varchar not null primary key,
+    name varchar not null,
+    description varchar not null default '',
+    created_by bigint not null references users (id),
+    created_at timestamptz not null default now()
+);
@@ -0,0 +1,9 @@
+CREATE TABLE messages (
+    id bigserial not null primary key,
+    room_id varchar not null references rooms (id) on delete cascade,
+    user_id bigint not null references users (id),
+    content text not null,
+    created_at timestamptz not null default now()
+);
+
+CREATE INDEX messages_room_id_id_idx ON messages (room_id, id);
@@ -0,0 +1 @@
+ALTER TABLE rooms DROP COLUMN history_size;
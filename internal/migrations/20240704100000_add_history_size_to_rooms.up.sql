@@ -0,0 +1 @@
+ALTER TABLE rooms ADD COLUMN history_size int not null default 50;
@@ -0,0 +1,3 @@
+DELETE FROM rooms WHERE id = 'general';
+
+ALTER TABLE rooms ALTER COLUMN created_by SET NOT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE rooms ALTER COLUMN created_by DROP NOT NULL;
+
+INSERT INTO rooms (id, name, description) VALUES ('general', 'General', 'Shared chat for everyone');
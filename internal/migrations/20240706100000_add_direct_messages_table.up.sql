@@ -0,0 +1,11 @@
+CREATE TABLE direct_messages (
+    id bigserial not null primary key,
+    sender_id bigint not null references users (id),
+    recipient_id bigint not null references users (id),
+    content text not null,
+    created_at timestamptz not null default now(),
+    read_at timestamptz
+);
+
+CREATE INDEX direct_messages_sender_recipient_idx ON direct_messages (sender_id, recipient_id, id);
+CREATE INDEX direct_messages_recipient_sender_idx ON direct_messages (recipient_id, sender_id, id);
@@ -0,0 +1 @@
+DROP TABLE ws_broker_payloads;
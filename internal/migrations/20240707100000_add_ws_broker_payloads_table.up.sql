@@ -0,0 +1,7 @@
+CREATE TABLE ws_broker_payloads (
+    id bigserial not null primary key,
+    payload bytea not null,
+    created_at timestamptz not null default now()
+);
+
+CREATE INDEX ws_broker_payloads_created_at_idx ON ws_broker_payloads (created_at);
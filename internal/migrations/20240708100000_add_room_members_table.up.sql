@@ -0,0 +1,8 @@
+CREATE TABLE room_members (
+    room_id varchar not null references rooms (id) on delete cascade,
+    user_id bigint not null references users (id),
+    joined_at timestamptz not null default now(),
+    primary key (room_id, user_id)
+);
+
+CREATE INDEX room_members_user_id_idx ON room_members (user_id);
@@ -0,0 +1,2 @@
+ALTER TABLE rooms DROP COLUMN archived_at;
+ALTER TABLE rooms DROP COLUMN topic;
@@ -0,0 +1,2 @@
+ALTER TABLE rooms ADD COLUMN topic varchar not null default '';
+ALTER TABLE rooms ADD COLUMN archived_at timestamptz;
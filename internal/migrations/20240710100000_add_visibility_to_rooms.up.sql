@@ -0,0 +1,2 @@
+ALTER TABLE rooms ADD COLUMN visibility varchar not null default 'public'
+    CHECK (visibility IN ('public', 'private', 'invite_only'));
@@ -0,0 +1,11 @@
+CREATE TABLE room_invites (
+    code varchar not null primary key,
+    room_id varchar not null references rooms (id) on delete cascade,
+    created_by bigint not null references users (id),
+    expires_at timestamptz,
+    max_uses int not null default 0,
+    uses int not null default 0,
+    created_at timestamptz not null default now()
+);
+
+CREATE INDEX room_invites_room_id_idx ON room_invites (room_id);
@@ -0,0 +1 @@
+DROP TABLE room_join_requests;
@@ -0,0 +1,9 @@
+CREATE TABLE room_join_requests (
+    room_id varchar not null references rooms (id) on delete cascade,
+    user_id bigint not null references users (id),
+    status varchar not null default 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    created_at timestamptz not null default now(),
+    decided_by bigint references users (id),
+    decided_at timestamptz,
+    primary key (room_id, user_id)
+);
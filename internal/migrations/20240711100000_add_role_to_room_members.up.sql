@@ -0,0 +1,6 @@
+ALTER TABLE room_members ADD COLUMN role varchar not null default 'member'
+    CHECK (role IN ('owner', 'moderator', 'member'));
+
+UPDATE room_members m SET role = 'owner'
+FROM rooms r
+WHERE r.id = m.room_id AND r.created_by = m.user_id;
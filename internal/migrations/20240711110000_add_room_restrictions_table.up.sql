@@ -0,0 +1,10 @@
+CREATE TABLE room_restrictions (
+    room_id varchar not null references rooms (id) on delete cascade,
+    user_id bigint not null references users (id),
+    kind varchar not null CHECK (kind IN ('ban', 'mute')),
+    reason varchar not null default '',
+    created_by bigint not null references users (id),
+    expires_at timestamptz,
+    created_at timestamptz not null default now(),
+    primary key (room_id, user_id, kind)
+);
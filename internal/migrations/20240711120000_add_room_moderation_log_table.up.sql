@@ -0,0 +1,13 @@
+CREATE TABLE room_moderation_log (
+    id bigserial not null primary key,
+    room_id varchar not null references rooms (id) on delete cascade,
+    action varchar not null,
+    actor_id bigint not null references users (id),
+    target_id bigint not null references users (id),
+    reason varchar not null default '',
+    role varchar not null default '',
+    expires_at timestamptz,
+    created_at timestamptz not null default now()
+);
+
+CREATE INDEX room_moderation_log_room_id_id_idx ON room_moderation_log (room_id, id);
@@ -0,0 +1,13 @@
+ALTER TABLE messages ADD COLUMN edited_at timestamptz;
+ALTER TABLE messages ADD COLUMN deleted_at timestamptz;
+ALTER TABLE messages ADD COLUMN deleted_by bigint references users (id);
+
+CREATE TABLE message_edits (
+    id bigserial not null primary key,
+    message_id bigint not null references messages (id) on delete cascade,
+    content text not null,
+    edited_by bigint not null references users (id),
+    edited_at timestamptz not null default now()
+);
+
+CREATE INDEX message_edits_message_id_idx ON message_edits (message_id, id);
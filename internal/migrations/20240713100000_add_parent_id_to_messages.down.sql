@@ -0,0 +1,3 @@
+DROP INDEX messages_parent_id_id_idx;
+
+ALTER TABLE messages DROP COLUMN parent_id;
@@ -0,0 +1,3 @@
+ALTER TABLE messages ADD COLUMN parent_id bigint references messages (id) on delete cascade;
+
+CREATE INDEX messages_parent_id_id_idx ON messages (parent_id, id) WHERE parent_id IS NOT NULL;
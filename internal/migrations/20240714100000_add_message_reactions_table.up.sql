@@ -0,0 +1,7 @@
+CREATE TABLE message_reactions (
+    message_id bigint not null references messages (id) on delete cascade,
+    user_id bigint not null references users (id) on delete cascade,
+    emoji varchar(64) not null,
+    created_at timestamptz not null default now(),
+    primary key (message_id, user_id, emoji)
+);
@@ -0,0 +1 @@
+ALTER TABLE room_members ADD COLUMN last_read_id bigint not null default 0;
@@ -0,0 +1,6 @@
+CREATE TABLE user_presence (
+    user_id bigint not null primary key references users (id) on delete cascade,
+    status varchar not null default 'online' CHECK (status IN ('online', 'away', 'dnd')),
+    status_text varchar not null default '',
+    last_seen_at timestamptz
+);
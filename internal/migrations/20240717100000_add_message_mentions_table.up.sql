@@ -0,0 +1,12 @@
+CREATE TABLE message_mentions (
+    id bigserial not null primary key,
+    message_id bigint not null references messages (id) on delete cascade,
+    kind varchar not null CHECK (kind IN ('user', 'room')),
+    user_id bigint references users (id) on delete cascade,
+    start int not null,
+    length int not null,
+    CHECK ((kind = 'user') = (user_id IS NOT NULL))
+);
+
+CREATE INDEX message_mentions_message_id_idx ON message_mentions (message_id);
+CREATE INDEX message_mentions_user_id_idx ON message_mentions (user_id) WHERE user_id IS NOT NULL;
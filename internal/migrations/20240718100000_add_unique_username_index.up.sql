@@ -0,0 +1,6 @@
+-- names taken more than once keep their first owner, the others get their id appended
+UPDATE users u SET username = u.username || '_' || u.id
+WHERE u.username <> ''
+    AND EXISTS (SELECT 1 FROM users o WHERE lower(o.username) = lower(u.username) AND o.id < u.id);
+
+CREATE UNIQUE INDEX users_username_lower_idx ON users (lower(username)) WHERE username <> '';
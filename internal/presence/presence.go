@@ -0,0 +1,63 @@
+package presence
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusOnline Status = "online"
+	StatusAway   Status = "away"
+	StatusDND    Status = "dnd"
+	// StatusOffline is never stored, users show as offline while they have
+	// no connection.
+	StatusOffline Status = "offline"
+)
+
+// Valid reports whether users may set the status.
+func (s Status) Valid() bool {
+	switch s {
+	case StatusOnline, StatusAway, StatusDND:
+		return true
+	}
+	return false
+}
+
+const MaxTextLength = 100
+
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrInvalidStatus = errors.New("invalid status")
+	ErrTextTooLong   = errors.New("status text is too long")
+)
+
+// Presence is how a user shows to others: the status they set with an
+// optional text, and when they were last seen connected.
+type Presence struct {
+	UserID     int64      `json:"userId"`
+	Username   string     `json:"username"`
+	Status     Status     `json:"status"`
+	Text       string     `json:"text"`
+	LastSeenAt *time.Time `json:"lastSeenAt,omitempty"`
+}
+
+// StatusReq sets the status of the caller, Text is shown next to it.
+type StatusReq struct {
+	Status string `json:"status"`
+	Text   string `json:"text"`
+}
+
+type Repository interface {
+	// GetPresence returns the stored presence of the user, online without a
+	// text for users that never set a status.
+	GetPresence(ctx context.Context, userID int64) (*Presence, error)
+	SetStatus(ctx context.Context, userID int64, status Status, text string) (*Presence, error)
+	// Touch records that the user was seen now and returns their presence.
+	Touch(ctx context.Context, userID int64) (*Presence, error)
+	// Contacts returns the users sharing a room or a direct message
+	// conversation with the user.
+	Contacts(ctx context.Context, userID int64) ([]int64, error)
+}
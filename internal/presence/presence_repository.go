@@ -0,0 +1,120 @@
+package presence
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+)
+
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(context.Context, string) (*sql.Stmt, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+type repository struct {
+	db DBTX
+}
+
+func NewRepository(db DBTX) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) GetPresence(ctx context.Context, userID int64) (*Presence, error) {
+	const op = "presence.Repository.GetPresence"
+	p := Presence{}
+
+	query := `SELECT u.id, u.username, COALESCE(p.status, 'online'), COALESCE(p.status_text, ''), p.last_seen_at
+		FROM users u LEFT JOIN user_presence p ON p.user_id = u.id
+		WHERE u.id = $1`
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&p.UserID, &p.Username, &p.Status, &p.Text, &p.LastSeenAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, op)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return &p, nil
+}
+
+func (r *repository) SetStatus(ctx context.Context, userID int64, status Status, text string) (*Presence, error) {
+	const op = "presence.Repository.SetStatus"
+
+	query := `INSERT INTO user_presence (user_id, status, status_text) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET status = EXCLUDED.status, status_text = EXCLUDED.status_text`
+	if _, err := r.db.ExecContext(ctx, query, userID, status, text); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return nil, fmt.Errorf("%w: %s", ErrUserNotFound, op)
+		}
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	p, err := r.GetPresence(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return p, nil
+}
+
+func (r *repository) Touch(ctx context.Context, userID int64) (*Presence, error) {
+	const op = "presence.Repository.Touch"
+	p := Presence{}
+
+	query := `WITH seen AS (
+			INSERT INTO user_presence (user_id, last_seen_at) VALUES ($1, now())
+			ON CONFLICT (user_id) DO UPDATE SET last_seen_at = EXCLUDED.last_seen_at
+			RETURNING user_id, status, status_text, last_seen_at
+		)
+		SELECT u.id, u.username, s.status, s.status_text, s.last_seen_at
+		FROM seen s JOIN users u ON u.id = s.user_id`
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&p.UserID, &p.Username, &p.Status, &p.Text, &p.LastSeenAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return nil, fmt.Errorf("%w: %s", ErrUserNotFound, op)
+		}
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return &p, nil
+}
+
+func (r *repository) Contacts(ctx context.Context, userID int64) ([]int64, error) {
+	const op = "presence.Repository.Contacts"
+
+	query := `SELECT c.user_id FROM (
+			SELECT b.user_id
+			FROM room_members a JOIN room_members b ON b.room_id = a.room_id
+			WHERE a.user_id = $1
+			UNION
+			SELECT CASE WHEN sender_id = $1 THEN recipient_id ELSE sender_id END
+			FROM direct_messages
+			WHERE sender_id = $1 OR recipient_id = $1
+		) c
+		WHERE c.user_id <> $1`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+	defer rows.Close()
+
+	contacts := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, op)
+		}
+		contacts = append(contacts, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return contacts, nil
+}
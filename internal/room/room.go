@@ -0,0 +1,265 @@
+package room
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultHistorySize is how many messages are replayed to a joining user when
+// the room doesn't say otherwise.
+const DefaultHistorySize = 50
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 100
+)
+
+var (
+	ErrRoomNotFound = errors.New("room not found")
+	ErrRoomExists   = errors.New("room already exists")
+	ErrRoomArchived = errors.New("room is archived")
+
+	ErrInviteNotFound      = errors.New("invite not found")
+	ErrInviteExpired       = errors.New("invite has expired")
+	ErrInviteExhausted     = errors.New("invite has no uses left")
+	ErrJoinRequestNotFound = errors.New("join request not found")
+	ErrMemberNotFound      = errors.New("member not found")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrRestrictionNotFound = errors.New("restriction not found")
+)
+
+// Role is the standing of a member in a room. Moderators may kick, ban and
+// mute members, the owner may also moderate moderators and appoint them.
+type Role string
+
+const (
+	RoleOwner     Role = "owner"
+	RoleModerator Role = "moderator"
+	RoleMember    Role = "member"
+)
+
+// Rank orders roles, users that aren't members rank lowest.
+func (r Role) Rank() int {
+	switch r {
+	case RoleOwner:
+		return 3
+	case RoleModerator:
+		return 2
+	case RoleMember:
+		return 1
+	}
+	return 0
+}
+
+// RestrictionKind is what a restricted user can't do in a room: banned users
+// can't join it, muted users can't send to it.
+type RestrictionKind string
+
+const (
+	RestrictionBan  RestrictionKind = "ban"
+	RestrictionMute RestrictionKind = "mute"
+)
+
+// Restriction is an active ban or mute. ExpiresAt is nil for permanent ones.
+type Restriction struct {
+	RoomID    string          `json:"roomId"`
+	UserID    int64           `json:"userId"`
+	Username  string          `json:"username"`
+	Kind      RestrictionKind `json:"kind"`
+	Reason    string          `json:"reason"`
+	CreatedBy int64           `json:"createdBy"`
+	ExpiresAt *time.Time      `json:"expiresAt,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+type Action string
+
+const (
+	ActionKick   Action = "kick"
+	ActionBan    Action = "ban"
+	ActionUnban  Action = "unban"
+	ActionMute   Action = "mute"
+	ActionUnmute Action = "unmute"
+	ActionRole   Action = "role"
+)
+
+// ModerationEntry is a moderation action in the audit trail of a room. Role
+// is the new role for ActionRole, ExpiresAt the end of a ban or mute.
+type ModerationEntry struct {
+	ID         int64      `json:"id"`
+	RoomID     string     `json:"roomId"`
+	Action     Action     `json:"action"`
+	ActorID    int64      `json:"actorId"`
+	ActorName  string     `json:"actorName"`
+	TargetID   int64      `json:"targetId"`
+	TargetName string     `json:"targetName"`
+	Reason     string     `json:"reason,omitempty"`
+	Role       Role       `json:"role,omitempty"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// Visibility decides who may join a room. Anyone can join a public room.
+// Private rooms are listed and users ask the owner to let them in, invite-only
+// rooms are hidden and only open to invited users.
+type Visibility string
+
+const (
+	VisibilityPublic     Visibility = "public"
+	VisibilityPrivate    Visibility = "private"
+	VisibilityInviteOnly Visibility = "invite_only"
+)
+
+func (v Visibility) Valid() bool {
+	switch v {
+	case VisibilityPublic, VisibilityPrivate, VisibilityInviteOnly:
+		return true
+	}
+	return false
+}
+
+// Room is a stored chat room. CreatedBy is 0 for rooms created by the system,
+// like the general chat.
+type Room struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Topic       string     `json:"topic"`
+	Visibility  Visibility `json:"visibility"`
+	HistorySize int        `json:"historySize"`
+	CreatedBy   int64      `json:"createdBy"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	// ArchivedAt is set while the room is read-only.
+	ArchivedAt *time.Time `json:"archivedAt,omitempty"`
+}
+
+// Update holds the fields of a room to change, nil fields are left as is.
+type Update struct {
+	Name        *string
+	Description *string
+	Topic       *string
+	Visibility  *Visibility
+	Archived    *bool
+}
+
+// Invite lets whoever has the code join the room. MaxUses is 0 for unlimited
+// invites, ExpiresAt is nil for invites that don't expire.
+type Invite struct {
+	Code      string     `json:"code"`
+	RoomID    string     `json:"roomId"`
+	CreatedBy int64      `json:"createdBy"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	MaxUses   int        `json:"maxUses"`
+	Uses      int        `json:"uses"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+type JoinRequestStatus string
+
+const (
+	JoinRequestPending  JoinRequestStatus = "pending"
+	JoinRequestApproved JoinRequestStatus = "approved"
+	JoinRequestRejected JoinRequestStatus = "rejected"
+)
+
+// JoinRequest is a user asking to join a private room.
+type JoinRequest struct {
+	RoomID    string            `json:"roomId"`
+	UserID    int64             `json:"userId"`
+	Username  string            `json:"username"`
+	Status    JoinRequestStatus `json:"status"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// Summary is a room as shown in listings.
+// Summary is a listed room. LastReadID and UnreadCount tell how far the
+// viewer has read the room and how many messages of others are left, both
+// zero for rooms the viewer isn't a member of.
+type Summary struct {
+	Room
+	MemberCount int   `json:"memberCount"`
+	LastReadID  int64 `json:"lastReadId"`
+	UnreadCount int   `json:"unreadCount"`
+}
+
+// Member is a user that has joined a room at least once. LastReadID is the
+// last message of the room the member has read.
+type Member struct {
+	UserID     int64     `json:"userId"`
+	Username   string    `json:"username"`
+	Role       Role      `json:"role"`
+	JoinedAt   time.Time `json:"joinedAt"`
+	LastReadID int64     `json:"lastReadId"`
+}
+
+// ListQuery selects a page of a listing. Search filters rooms by a case
+// insensitive substring of their name. Invite-only rooms are only listed to
+// their members, ViewerID, unless All is set.
+type ListQuery struct {
+	Search   string
+	ViewerID int64
+	All      bool
+	Limit    int
+	Offset   int
+}
+
+// Normalize clamps the limit into [1, MaxListLimit].
+func (q ListQuery) Normalize() ListQuery {
+	if q.Limit <= 0 {
+		q.Limit = DefaultListLimit
+	}
+	if q.Limit > MaxListLimit {
+		q.Limit = MaxListLimit
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+	return q
+}
+
+type Repository interface {
+	CreateRoom(ctx context.Context, room *Room) (*Room, error)
+	GetRoomByID(ctx context.Context, id string) (*Room, error)
+	// ListRooms returns a page of rooms and whether more follow it.
+	ListRooms(ctx context.Context, q ListQuery) ([]*Summary, bool, error)
+	UpdateRoom(ctx context.Context, id string, u Update) (*Room, error)
+	// DeleteRoom removes the room along with its messages and members.
+	DeleteRoom(ctx context.Context, id string) error
+
+	// AddMember records that the user joined the room, it's a no-op for
+	// existing members.
+	AddMember(ctx context.Context, roomID string, userID int64, role Role) error
+	IsMember(ctx context.Context, roomID string, userID int64) (bool, error)
+	// MemberRole returns ErrMemberNotFound for users that aren't members.
+	MemberRole(ctx context.Context, roomID string, userID int64) (Role, error)
+	ListMembers(ctx context.Context, roomID string, q ListQuery) ([]*Member, bool, error)
+	// MarkRead moves the read position of the user in the room forward to
+	// messageID, making them a member, and returns the resulting position.
+	MarkRead(ctx context.Context, roomID string, userID, messageID int64) (int64, error)
+
+	CreateInvite(ctx context.Context, invite *Invite) (*Invite, error)
+	GetInvite(ctx context.Context, code string) (*Invite, error)
+	// UseInvite spends one use of the invite and makes the user a member of
+	// its room.
+	UseInvite(ctx context.Context, code string, userID int64) (*Invite, error)
+	DeleteInvite(ctx context.Context, roomID, code string) error
+
+	// CreateJoinRequest files a pending request, reopening a decided one.
+	CreateJoinRequest(ctx context.Context, roomID string, userID int64) (*JoinRequest, error)
+	ListJoinRequests(ctx context.Context, roomID string, q ListQuery) ([]*JoinRequest, bool, error)
+	// DecideJoinRequest approves or rejects a pending request, approved users
+	// become members.
+	DecideJoinRequest(ctx context.Context, roomID string, userID, decidedBy int64, approve bool) error
+
+	// Moderate applies a moderation action and records it in the audit trail.
+	Moderate(ctx context.Context, e *ModerationEntry) (*ModerationEntry, error)
+	ListModeration(ctx context.Context, roomID string, q ListQuery) ([]*ModerationEntry, bool, error)
+	// ActiveRestriction returns ErrRestrictionNotFound unless the user is
+	// currently restricted.
+	ActiveRestriction(ctx context.Context, roomID string, userID int64, kind RestrictionKind) (*Restriction, error)
+	ListRestrictions(ctx context.Context, roomID string, kind RestrictionKind, q ListQuery) ([]*Restriction, bool, error)
+	// MutedUsers returns when the mutes of every muted user of the room end,
+	// nil for permanent ones.
+	MutedUsers(ctx context.Context, roomID string) (map[int64]*time.Time, error)
+}
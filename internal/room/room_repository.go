@@ -0,0 +1,624 @@
+package room
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"strings"
+	"time"
+)
+
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(context.Context, string) (*sql.Stmt, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+// roomColumns are the columns scanned by Room.fields, in order.
+const roomColumns = "id, name, description, topic, visibility, history_size, COALESCE(created_by, 0), created_at, archived_at"
+
+func (r *Room) fields() []interface{} {
+	return []interface{}{&r.ID, &r.Name, &r.Description, &r.Topic, &r.Visibility, &r.HistorySize, &r.CreatedBy, &r.CreatedAt, &r.ArchivedAt}
+}
+
+const inviteColumns = "code, room_id, created_by, expires_at, max_uses, uses, created_at"
+
+func (i *Invite) fields() []interface{} {
+	return []interface{}{&i.Code, &i.RoomID, &i.CreatedBy, &i.ExpiresAt, &i.MaxUses, &i.Uses, &i.CreatedAt}
+}
+
+type repository struct {
+	db DBTX
+}
+
+func NewRepository(db DBTX) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) CreateRoom(ctx context.Context, room *Room) (*Room, error) {
+	const op = "room.Repository.CreateRoom"
+
+	query := "INSERT INTO rooms (id, name, description, topic, visibility, history_size, created_by) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING created_at"
+	err := r.db.QueryRowContext(ctx, query, room.ID, room.Name, room.Description, room.Topic, room.Visibility, room.HistorySize, room.CreatedBy).
+		Scan(&room.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, fmt.Errorf("%w: %s", ErrRoomExists, op)
+		}
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return room, nil
+}
+
+func (r *repository) GetRoomByID(ctx context.Context, id string) (*Room, error) {
+	const op = "room.Repository.GetRoomByID"
+	room := Room{}
+
+	query := "SELECT " + roomColumns + " FROM rooms WHERE id = $1"
+	err := r.db.QueryRowContext(ctx, query, id).Scan(room.fields()...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrRoomNotFound, op)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return &room, nil
+}
+
+func (r *repository) ListRooms(ctx context.Context, q ListQuery) ([]*Summary, bool, error) {
+	const op = "room.Repository.ListRooms"
+	q = q.Normalize()
+
+	// v is the membership of the viewer; threads, deleted messages and the
+	// viewer's own messages don't count as unread
+	query := `SELECT ` + roomColumns + `,
+			(SELECT count(*) FROM room_members m WHERE m.room_id = r.id),
+			COALESCE(v.last_read_id, 0),
+			CASE WHEN v.user_id IS NULL THEN 0 ELSE (
+				SELECT count(*) FROM messages msg
+				WHERE msg.room_id = r.id AND msg.id > v.last_read_id AND msg.user_id <> $5
+					AND msg.parent_id IS NULL AND msg.deleted_at IS NULL
+			) END
+		FROM rooms r
+		LEFT JOIN room_members v ON v.room_id = r.id AND v.user_id = $5
+		WHERE ($1 = '' OR r.name ILIKE '%' || $1 || '%')
+			AND (r.visibility <> 'invite_only' OR $4 OR r.created_by = $5
+				OR EXISTS (SELECT 1 FROM room_members m WHERE m.room_id = r.id AND m.user_id = $5))
+		ORDER BY r.created_at, r.id
+		LIMIT $2 OFFSET $3`
+	rows, err := r.db.QueryContext(ctx, query, escapeLike(q.Search), q.Limit+1, q.Offset, q.All, q.ViewerID)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %s", err, op)
+	}
+	defer rows.Close()
+
+	rooms := make([]*Summary, 0, q.Limit)
+	for rows.Next() {
+		s := Summary{}
+		if err := rows.Scan(append(s.fields(), &s.MemberCount, &s.LastReadID, &s.UnreadCount)...); err != nil {
+			return nil, false, fmt.Errorf("%w: %s", err, op)
+		}
+		rooms = append(rooms, &s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("%w: %s", err, op)
+	}
+
+	hasMore := len(rooms) > q.Limit
+	if hasMore {
+		rooms = rooms[:q.Limit]
+	}
+	return rooms, hasMore, nil
+}
+
+func (r *repository) UpdateRoom(ctx context.Context, id string, u Update) (*Room, error) {
+	const op = "room.Repository.UpdateRoom"
+	room := Room{}
+
+	// a NULL argument keeps the current value
+	query := `UPDATE rooms SET
+			name = COALESCE($2, name),
+			description = COALESCE($3, description),
+			topic = COALESCE($4, topic),
+			visibility = COALESCE($6, visibility),
+			archived_at = CASE
+				WHEN $5::boolean IS NULL THEN archived_at
+				WHEN $5::boolean THEN COALESCE(archived_at, now())
+				ELSE NULL END
+		WHERE id = $1
+		RETURNING ` + roomColumns
+	err := r.db.QueryRowContext(ctx, query, id, u.Name, u.Description, u.Topic, u.Archived, u.Visibility).Scan(room.fields()...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrRoomNotFound, op)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return &room, nil
+}
+
+func (r *repository) DeleteRoom(ctx context.Context, id string) error {
+	const op = "room.Repository.DeleteRoom"
+
+	res, err := r.db.ExecContext(ctx, "DELETE FROM rooms WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, op)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, op)
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %s", ErrRoomNotFound, op)
+	}
+
+	return nil
+}
+
+// escapeLike makes s match literally inside a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func (r *repository) AddMember(ctx context.Context, roomID string, userID int64, role Role) error {
+	const op = "room.Repository.AddMember"
+
+	query := "INSERT INTO room_members (room_id, user_id, role) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING"
+	if _, err := r.db.ExecContext(ctx, query, roomID, userID, role); err != nil {
+		return fmt.Errorf("%w: %s", err, op)
+	}
+
+	return nil
+}
+
+func (r *repository) ListMembers(ctx context.Context, roomID string, q ListQuery) ([]*Member, bool, error) {
+	const op = "room.Repository.ListMembers"
+	q = q.Normalize()
+
+	query := `SELECT m.user_id, u.username, m.role, m.joined_at, m.last_read_id
+		FROM room_members m JOIN users u ON u.id = m.user_id
+		WHERE m.room_id = $1
+		ORDER BY m.joined_at, m.user_id
+		LIMIT $2 OFFSET $3`
+	rows, err := r.db.QueryContext(ctx, query, roomID, q.Limit+1, q.Offset)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %s", err, op)
+	}
+	defer rows.Close()
+
+	members := make([]*Member, 0, q.Limit)
+	for rows.Next() {
+		m := Member{}
+		if err := rows.Scan(&m.UserID, &m.Username, &m.Role, &m.JoinedAt, &m.LastReadID); err != nil {
+			return nil, false, fmt.Errorf("%w: %s", err, op)
+		}
+		members = append(members, &m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("%w: %s", err, op)
+	}
+
+	hasMore := len(members) > q.Limit
+	if hasMore {
+		members = members[:q.Limit]
+	}
+	return members, hasMore, nil
+}
+
+func (r *repository) MarkRead(ctx context.Context, roomID string, userID, messageID int64) (int64, error) {
+	const op = "room.Repository.MarkRead"
+
+	var lastReadID int64
+	query := `INSERT INTO room_members (room_id, user_id, last_read_id) VALUES ($1, $2, $3)
+		ON CONFLICT (room_id, user_id) DO UPDATE
+			SET last_read_id = GREATEST(room_members.last_read_id, EXCLUDED.last_read_id)
+		RETURNING last_read_id`
+	err := r.db.QueryRowContext(ctx, query, roomID, userID, messageID).Scan(&lastReadID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return 0, fmt.Errorf("%w: %s", ErrRoomNotFound, op)
+		}
+		return 0, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return lastReadID, nil
+}
+
+func (r *repository) IsMember(ctx context.Context, roomID string, userID int64) (bool, error) {
+	const op = "room.Repository.IsMember"
+
+	var ok bool
+	query := "SELECT EXISTS (SELECT 1 FROM room_members WHERE room_id = $1 AND user_id = $2)"
+	if err := r.db.QueryRowContext(ctx, query, roomID, userID).Scan(&ok); err != nil {
+		return false, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return ok, nil
+}
+
+func (r *repository) MemberRole(ctx context.Context, roomID string, userID int64) (Role, error) {
+	const op = "room.Repository.MemberRole"
+
+	var role Role
+	query := "SELECT role FROM room_members WHERE room_id = $1 AND user_id = $2"
+	err := r.db.QueryRowContext(ctx, query, roomID, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("%w: %s", ErrMemberNotFound, op)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, op)
+	}
+
+	return role, nil
+}
+
+func (r *repository) CreateInvite(ctx context.Context, invite *Invite) (*Invite, error) {
+	const op = "room.Repository.CreateInvite"
+
+	query := `INSERT INTO room_invites (code, room_id, created_by, expires_at, max_uses)
+		VALUES ($1, $2, $3, $4, $5) RETURNING created_at`
+	err := r.db.QueryRowContext(ctx, query, invite.Code, invite.RoomID, invite.CreatedBy, invite.ExpiresAt, invite.MaxUses).
+		Scan(&invite.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return nil, fmt.Errorf("%w: %s", ErrRoomNotFound, op)
+		}
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return invite, nil
+}
+
+func (r *repository) GetInvite(ctx context.Context, code string) (*Invite, error) {
+	const op = "room.Repository.GetInvite"
+	invite := Invite{}
+
+	query := "SELECT " + inviteColumns + " FROM room_invites WHERE code = $1"
+	err := r.db.QueryRowContext(ctx, query, code).Scan(invite.fields()...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrInviteNotFound, op)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return &invite, nil
+}
+
+func (r *repository) UseInvite(ctx context.Context, code string, userID int64) (*Invite, error) {
+	const op = "room.Repository.UseInvite"
+	invite := Invite{}
+
+	// spending the use and adding the member happen in one statement so that
+	// concurrent joins can't go over max_uses
+	query := `WITH used AS (
+			UPDATE room_invites SET uses = uses + 1
+			WHERE code = $1
+				AND (expires_at IS NULL OR expires_at > now())
+				AND (max_uses = 0 OR uses < max_uses)
+			RETURNING ` + inviteColumns + `
+		), joined AS (
+			INSERT INTO room_members (room_id, user_id)
+			SELECT room_id, $2 FROM used
+			ON CONFLICT DO NOTHING
+		)
+		SELECT ` + inviteColumns + ` FROM used`
+	err := r.db.QueryRowContext(ctx, query, code, userID).Scan(invite.fields()...)
+	if errors.Is(err, sql.ErrNoRows) {
+		// tell why the invite couldn't be used
+		stored, err := r.GetInvite(ctx, code)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, op)
+		}
+		if stored.ExpiresAt != nil && !stored.ExpiresAt.After(time.Now()) {
+			return nil, fmt.Errorf("%w: %s", ErrInviteExpired, op)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrInviteExhausted, op)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return &invite, nil
+}
+
+func (r *repository) DeleteInvite(ctx context.Context, roomID, code string) error {
+	const op = "room.Repository.DeleteInvite"
+
+	res, err := r.db.ExecContext(ctx, "DELETE FROM room_invites WHERE room_id = $1 AND code = $2", roomID, code)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, op)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, op)
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %s", ErrInviteNotFound, op)
+	}
+
+	return nil
+}
+
+func (r *repository) CreateJoinRequest(ctx context.Context, roomID string, userID int64) (*JoinRequest, error) {
+	const op = "room.Repository.CreateJoinRequest"
+	req := JoinRequest{}
+
+	query := `WITH req AS (
+			INSERT INTO room_join_requests (room_id, user_id) VALUES ($1, $2)
+			ON CONFLICT (room_id, user_id) DO UPDATE
+				SET status = 'pending', created_at = now(), decided_by = NULL, decided_at = NULL
+				WHERE room_join_requests.status <> 'pending'
+			RETURNING room_id, user_id, status, created_at
+		)
+		SELECT req.room_id, req.user_id, u.username, req.status, req.created_at
+		FROM req JOIN users u ON u.id = req.user_id`
+	err := r.db.QueryRowContext(ctx, query, roomID, userID).
+		Scan(&req.RoomID, &req.UserID, &req.Username, &req.Status, &req.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		// already pending, nothing was written
+		query = `SELECT j.room_id, j.user_id, u.username, j.status, j.created_at
+			FROM room_join_requests j JOIN users u ON u.id = j.user_id
+			WHERE j.room_id = $1 AND j.user_id = $2`
+		err = r.db.QueryRowContext(ctx, query, roomID, userID).
+			Scan(&req.RoomID, &req.UserID, &req.Username, &req.Status, &req.CreatedAt)
+	}
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return nil, fmt.Errorf("%w: %s", ErrRoomNotFound, op)
+		}
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return &req, nil
+}
+
+func (r *repository) ListJoinRequests(ctx context.Context, roomID string, q ListQuery) ([]*JoinRequest, bool, error) {
+	const op = "room.Repository.ListJoinRequests"
+	q = q.Normalize()
+
+	query := `SELECT j.room_id, j.user_id, u.username, j.status, j.created_at
+		FROM room_join_requests j JOIN users u ON u.id = j.user_id
+		WHERE j.room_id = $1 AND j.status = 'pending'
+		ORDER BY j.created_at, j.user_id
+		LIMIT $2 OFFSET $3`
+	rows, err := r.db.QueryContext(ctx, query, roomID, q.Limit+1, q.Offset)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %s", err, op)
+	}
+	defer rows.Close()
+
+	requests := make([]*JoinRequest, 0, q.Limit)
+	for rows.Next() {
+		req := JoinRequest{}
+		if err := rows.Scan(&req.RoomID, &req.UserID, &req.Username, &req.Status, &req.CreatedAt); err != nil {
+			return nil, false, fmt.Errorf("%w: %s", err, op)
+		}
+		requests = append(requests, &req)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("%w: %s", err, op)
+	}
+
+	hasMore := len(requests) > q.Limit
+	if hasMore {
+		requests = requests[:q.Limit]
+	}
+	return requests, hasMore, nil
+}
+
+func (r *repository) DecideJoinRequest(ctx context.Context, roomID string, userID, decidedBy int64, approve bool) error {
+	const op = "room.Repository.DecideJoinRequest"
+
+	status := JoinRequestRejected
+	if approve {
+		status = JoinRequestApproved
+	}
+
+	query := `WITH decided AS (
+			UPDATE room_join_requests SET status = $3, decided_by = $4, decided_at = now()
+			WHERE room_id = $1 AND user_id = $2 AND status = 'pending'
+			RETURNING room_id, user_id
+		), joined AS (
+			INSERT INTO room_members (room_id, user_id)
+			SELECT room_id, user_id FROM decided WHERE $5
+			ON CONFLICT DO NOTHING
+		)
+		SELECT count(*) FROM decided`
+	var n int
+	if err := r.db.QueryRowContext(ctx, query, roomID, userID, status, decidedBy, approve).Scan(&n); err != nil {
+		return fmt.Errorf("%w: %s", err, op)
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %s", ErrJoinRequestNotFound, op)
+	}
+
+	return nil
+}
+
+// moderationEffects are the statements applying each action, they get the
+// same arguments as the audit trail insert in Moderate.
+var moderationEffects = map[Action]string{
+	ActionBan: `INSERT INTO room_restrictions (room_id, user_id, kind, created_by, reason, expires_at)
+		VALUES ($1, $2, 'ban', $3, $4, $5)
+		ON CONFLICT (room_id, user_id, kind) DO UPDATE
+			SET created_by = EXCLUDED.created_by, reason = EXCLUDED.reason,
+				expires_at = EXCLUDED.expires_at, created_at = now()`,
+	ActionUnban: "DELETE FROM room_restrictions WHERE room_id = $1 AND user_id = $2 AND kind = 'ban'",
+	ActionMute: `INSERT INTO room_restrictions (room_id, user_id, kind, created_by, reason, expires_at)
+		VALUES ($1, $2, 'mute', $3, $4, $5)
+		ON CONFLICT (room_id, user_id, kind) DO UPDATE
+			SET created_by = EXCLUDED.created_by, reason = EXCLUDED.reason,
+				expires_at = EXCLUDED.expires_at, created_at = now()`,
+	ActionUnmute: "DELETE FROM room_restrictions WHERE room_id = $1 AND user_id = $2 AND kind = 'mute'",
+	ActionRole:   "UPDATE room_members SET role = $6 WHERE room_id = $1 AND user_id = $2",
+}
+
+func (r *repository) Moderate(ctx context.Context, e *ModerationEntry) (*ModerationEntry, error) {
+	const op = "room.Repository.Moderate"
+
+	// the effect and its audit entry are written in one statement
+	query := `WITH entry AS (
+			INSERT INTO room_moderation_log (room_id, target_id, actor_id, reason, expires_at, role, action)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id, actor_id, target_id, created_at
+		)
+		SELECT entry.id, entry.created_at, a.username, t.username
+		FROM entry
+		JOIN users a ON a.id = entry.actor_id
+		JOIN users t ON t.id = entry.target_id`
+	if effect, ok := moderationEffects[e.Action]; ok {
+		query = "WITH effect AS (" + effect + ")," + strings.TrimPrefix(query, "WITH")
+	}
+
+	err := r.db.QueryRowContext(ctx, query, e.RoomID, e.TargetID, e.ActorID, e.Reason, e.ExpiresAt, e.Role, e.Action).
+		Scan(&e.ID, &e.CreatedAt, &e.ActorName, &e.TargetName)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			if pqErr.Constraint == "room_moderation_log_room_id_fkey" {
+				return nil, fmt.Errorf("%w: %s", ErrRoomNotFound, op)
+			}
+			return nil, fmt.Errorf("%w: %s", ErrUserNotFound, op)
+		}
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return e, nil
+}
+
+func (r *repository) ListModeration(ctx context.Context, roomID string, q ListQuery) ([]*ModerationEntry, bool, error) {
+	const op = "room.Repository.ListModeration"
+	q = q.Normalize()
+
+	query := `SELECT l.id, l.room_id, l.action, l.actor_id, a.username, l.target_id, t.username,
+			l.reason, l.role, l.expires_at, l.created_at
+		FROM room_moderation_log l
+		JOIN users a ON a.id = l.actor_id
+		JOIN users t ON t.id = l.target_id
+		WHERE l.room_id = $1
+		ORDER BY l.id DESC
+		LIMIT $2 OFFSET $3`
+	rows, err := r.db.QueryContext(ctx, query, roomID, q.Limit+1, q.Offset)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %s", err, op)
+	}
+	defer rows.Close()
+
+	entries := make([]*ModerationEntry, 0, q.Limit)
+	for rows.Next() {
+		e := ModerationEntry{}
+		err := rows.Scan(&e.ID, &e.RoomID, &e.Action, &e.ActorID, &e.ActorName, &e.TargetID, &e.TargetName,
+			&e.Reason, &e.Role, &e.ExpiresAt, &e.CreatedAt)
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: %s", err, op)
+		}
+		entries = append(entries, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("%w: %s", err, op)
+	}
+
+	hasMore := len(entries) > q.Limit
+	if hasMore {
+		entries = entries[:q.Limit]
+	}
+	return entries, hasMore, nil
+}
+
+const restrictionColumns = "x.room_id, x.user_id, u.username, x.kind, x.reason, x.created_by, x.expires_at, x.created_at"
+
+func (x *Restriction) fields() []interface{} {
+	return []interface{}{&x.RoomID, &x.UserID, &x.Username, &x.Kind, &x.Reason, &x.CreatedBy, &x.ExpiresAt, &x.CreatedAt}
+}
+
+func (r *repository) ActiveRestriction(ctx context.Context, roomID string, userID int64, kind RestrictionKind) (*Restriction, error) {
+	const op = "room.Repository.ActiveRestriction"
+	x := Restriction{}
+
+	query := `SELECT ` + restrictionColumns + `
+		FROM room_restrictions x JOIN users u ON u.id = x.user_id
+		WHERE x.room_id = $1 AND x.user_id = $2 AND x.kind = $3
+			AND (x.expires_at IS NULL OR x.expires_at > now())`
+	err := r.db.QueryRowContext(ctx, query, roomID, userID, kind).Scan(x.fields()...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrRestrictionNotFound, op)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return &x, nil
+}
+
+func (r *repository) ListRestrictions(ctx context.Context, roomID string, kind RestrictionKind, q ListQuery) ([]*Restriction, bool, error) {
+	const op = "room.Repository.ListRestrictions"
+	q = q.Normalize()
+
+	query := `SELECT ` + restrictionColumns + `
+		FROM room_restrictions x JOIN users u ON u.id = x.user_id
+		WHERE x.room_id = $1 AND x.kind = $2
+			AND (x.expires_at IS NULL OR x.expires_at > now())
+		ORDER BY x.created_at, x.user_id
+		LIMIT $3 OFFSET $4`
+	rows, err := r.db.QueryContext(ctx, query, roomID, kind, q.Limit+1, q.Offset)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %s", err, op)
+	}
+	defer rows.Close()
+
+	restrictions := make([]*Restriction, 0, q.Limit)
+	for rows.Next() {
+		x := Restriction{}
+		if err := rows.Scan(x.fields()...); err != nil {
+			return nil, false, fmt.Errorf("%w: %s", err, op)
+		}
+		restrictions = append(restrictions, &x)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("%w: %s", err, op)
+	}
+
+	hasMore := len(restrictions) > q.Limit
+	if hasMore {
+		restrictions = restrictions[:q.Limit]
+	}
+	return restrictions, hasMore, nil
+}
+
+func (r *repository) MutedUsers(ctx context.Context, roomID string) (map[int64]*time.Time, error) {
+	const op = "room.Repository.MutedUsers"
+
+	query := `SELECT user_id, expires_at FROM room_restrictions
+		WHERE room_id = $1 AND kind = 'mute' AND (expires_at IS NULL OR expires_at > now())`
+	rows, err := r.db.QueryContext(ctx, query, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+	defer rows.Close()
+
+	muted := make(map[int64]*time.Time)
+	for rows.Next() {
+		var userID int64
+		var expiresAt *time.Time
+		if err := rows.Scan(&userID, &expiresAt); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, op)
+		}
+		muted[userID] = expiresAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return muted, nil
+}
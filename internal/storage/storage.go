@@ -16,16 +16,7 @@ func NewDB() (*sql.DB, error) {
 		return nil, fmt.Errorf("%s %w", op, err)
 	}
 
-	user := os.Getenv("DB_USER")
-	dbname := os.Getenv("DB_NAME")
-	password := os.Getenv("DB_PASSWORD")
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
-
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
-
-	db, err := sql.Open("postgres", psqlInfo)
+	db, err := sql.Open("postgres", DSN())
 	if err != nil {
 		return nil, fmt.Errorf("%s %w", op, err)
 	}
@@ -38,6 +29,18 @@ func NewDB() (*sql.DB, error) {
 	return db, nil
 }
 
+// DSN builds the connection string from the DB_* environment variables.
+func DSN() string {
+	user := os.Getenv("DB_USER")
+	dbname := os.Getenv("DB_NAME")
+	password := os.Getenv("DB_PASSWORD")
+	host := os.Getenv("DB_HOST")
+	port := os.Getenv("DB_PORT")
+
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		host, port, user, password, dbname)
+}
+
 func CloseDB(db *sql.DB) error {
 	const op = "storage.CloseDB"
 
@@ -2,13 +2,17 @@ package user
 
 import (
 	"context"
+	"errors"
 )
 
+var ErrUsernameTaken = errors.New("username is already taken")
+
 type User struct {
 	ID       int64  `json:"id"`
 	Username string `json:"username"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	IsAdmin  bool   `json:"isAdmin"`
 }
 
 type UserReq struct {
@@ -35,11 +39,17 @@ type ErrorResponse struct {
 }
 
 type Repository interface {
+	// CreateUser fails with ErrUsernameTaken when another user has the same
+	// username, ignoring case.
 	CreateUser(ctx context.Context, user *User) (*User, error)
 	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	// IsAdmin reports whether the user currently is an administrator, false
+	// for unknown users.
+	IsAdmin(ctx context.Context, userID int64) (bool, error)
 }
 
 type Service interface {
 	CreateUser(ctx context.Context, user *UserReq) (*UserRes, error)
 	Login(ctx context.Context, user *UserReq) (*LoginUser, error)
+	IsAdmin(ctx context.Context, userID int64) (bool, error)
 }
@@ -1,9 +1,12 @@
 package user
 
 import (
+	"HomeWork5/internal/middleware"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
+	"strconv"
 )
 
 type Handler struct {
@@ -46,6 +49,7 @@ func logResponseSuccess(log *slog.Logger, message string, statusCode int) {
 // @Param        user  body      UserReq  true  "User request body"
 // @Success      200   {object}  User
 // @Failure      400   {object}  ErrorResponse
+// @Failure      409   {object}  ErrorResponse
 // @Failure      500  {object}  ErrorResponse
 // @Router       /signup [post]
 func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
@@ -58,6 +62,10 @@ func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	userRes, err := h.Service.CreateUser(r.Context(), &u)
+	if errors.Is(err, ErrUsernameTaken) {
+		h.sendErrorResponse(w, "Username is already taken", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		h.sendErrorResponse(w, "Couldn't create a user", http.StatusInternalServerError)
 
@@ -124,3 +132,27 @@ func (h *Handler) LogoutUser(w http.ResponseWriter, r *http.Request) {
 	h.sendSuccessResponse(w, &UserRes{Message: "user was successfully logged out"}, "Logout successful", http.StatusOK)
 	http.Redirect(w, r, "/login", http.StatusSeeOther)
 }
+
+// CurrentUser godoc
+// @Summary      Get the authenticated user
+// @Description  Returns the user identified by the JWT from the token cookie or Authorization header.
+// @Tags         user
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  UserRes
+// @Failure      401  {object}  ErrorResponse
+// @Router       /users/me [get]
+func (h *Handler) CurrentUser(w http.ResponseWriter, r *http.Request) {
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		h.sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	h.sendSuccessResponse(w, &UserRes{
+		ID:       strconv.FormatInt(p.ID, 10),
+		Username: p.Username,
+		Email:    p.Email,
+		Message:  "user is authenticated",
+	}, "Current user resolved", http.StatusOK)
+}
@@ -3,7 +3,9 @@ package user
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"github.com/lib/pq"
 )
 
 type DBTX interface {
@@ -25,10 +27,14 @@ func (r *repository) CreateUser(ctx context.Context, user *User) (*User, error)
 	const op = "user.Repository.CreateUser"
 	var lastID int
 
-	query := "INSERT INTO users (email, encrypted_password) VALUES ($1, $2) RETURNING id"
-	err := r.db.QueryRowContext(ctx, query, user.Email, user.Password).Scan(&lastID)
+	query := "INSERT INTO users (username, email, encrypted_password) VALUES ($1, $2, $3) RETURNING id"
+	err := r.db.QueryRowContext(ctx, query, user.Username, user.Email, user.Password).Scan(&lastID)
 
 	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "users_username_lower_idx" {
+			return nil, fmt.Errorf("%w: %s", ErrUsernameTaken, op)
+		}
 		return nil, fmt.Errorf("%w: %s", err, op)
 	}
 
@@ -41,11 +47,26 @@ func (r *repository) GetUserByEmail(ctx context.Context, email string) (*User, e
 	const op = "user.Repository.GetUserByEmail"
 	u := User{}
 
-	query := "SELECT id, email, encrypted_password, created_at FROM users WHERE email = $1"
-	err := r.db.QueryRowContext(ctx, query, email).Scan(&u.ID, &u.Email, &u.Username, &u.Password)
+	query := "SELECT id, email, username, encrypted_password, is_admin FROM users WHERE email = $1"
+	err := r.db.QueryRowContext(ctx, query, email).Scan(&u.ID, &u.Email, &u.Username, &u.Password, &u.IsAdmin)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %s", err, op)
 	}
 
 	return &u, nil
 }
+
+func (r *repository) IsAdmin(ctx context.Context, userID int64) (bool, error) {
+	const op = "user.Repository.IsAdmin"
+	var admin bool
+
+	err := r.db.QueryRowContext(ctx, "SELECT is_admin FROM users WHERE id = $1", userID).Scan(&admin)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%w: %s", err, op)
+	}
+
+	return admin, nil
+}
@@ -65,7 +65,7 @@ func (s *service) Login(c context.Context, user *UserReq) (*LoginUser, error) {
 
 	flag := util.CheckPasswordHash(user.Password, dbUser.Password)
 	if !flag {
-		return nil, fmt.Errorf("%s: %w", op, "password is not correct")
+		return nil, fmt.Errorf("%s: password is not correct", op)
 	}
 
 	token, err := NewToken(*dbUser)
@@ -89,7 +89,8 @@ func NewToken(user User) (string, error) {
 	claims["uid"] = user.ID
 	claims["uname"] = user.Username
 	claims["uemail"] = user.Email
-	claims["exp"] = time.Now().Add(24 * time.Hour)
+	claims["uadmin"] = user.IsAdmin
+	claims["exp"] = time.Now().Add(24 * time.Hour).Unix()
 
 	tokenString, err := token.SignedString([]byte(os.Getenv("SECRET")))
 	if err != nil {
@@ -98,3 +99,17 @@ func NewToken(user User) (string, error) {
 
 	return tokenString, nil
 }
+
+func (s *service) IsAdmin(c context.Context, userID int64) (bool, error) {
+	const op = "user.IsAdmin"
+
+	ctx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	admin, err := s.Repository.IsAdmin(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return admin, nil
+}
@@ -0,0 +1,608 @@
+package ws
+
+import (
+	"HomeWork5/internal/message"
+	"HomeWork5/internal/middleware"
+	"HomeWork5/internal/room"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type CreateInviteReq struct {
+	// ExpiresIn is the lifetime of the invite in seconds, 0 for no expiry.
+	ExpiresIn int `json:"expiresIn"`
+	// MaxUses is how many users may join with the invite, 0 for no limit.
+	MaxUses int `json:"maxUses"`
+}
+
+type InviteRes struct {
+	Code      string     `json:"code"`
+	RoomID    string     `json:"roomId"`
+	CreatedBy int64      `json:"createdBy"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	MaxUses   int        `json:"maxUses"`
+	Uses      int        `json:"uses"`
+	CreatedAt time.Time  `json:"createdAt"`
+	// Link is the path to share, it shows the invite and accepting it joins
+	// the room.
+	Link string `json:"link"`
+}
+
+type JoinRequestsRes struct {
+	Requests []*room.JoinRequest `json:"requests"`
+	HasMore  bool                `json:"hasMore"`
+}
+
+func toInviteRes(i *room.Invite) *InviteRes {
+	return &InviteRes{
+		Code:      i.Code,
+		RoomID:    i.RoomID,
+		CreatedBy: i.CreatedBy,
+		ExpiresAt: i.ExpiresAt,
+		MaxUses:   i.MaxUses,
+		Uses:      i.Uses,
+		CreatedAt: i.CreatedAt,
+		Link:      "/invites/" + i.Code,
+	}
+}
+
+func newInviteCode() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// CanAccess reports whether the user may join and read the room. Public rooms
+// are open to everyone, the others to their members, owner and admins.
+func (h *Hub) CanAccess(ctx context.Context, rm *Room, userID int64, admin bool) (bool, error) {
+	const op = "ws.Hub.CanAccess"
+
+	if rm.Visibility() == room.VisibilityPublic || admin || rm.CreatedBy == userID {
+		return true, nil
+	}
+
+	ok, err := h.rooms.IsMember(ctx, rm.RoomId, userID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ok, nil
+}
+
+// CanRead implements message.AccessChecker: the user can't be banned from
+// the room, unless they own it or are an admin, and must have access to it.
+func (h *Hub) CanRead(ctx context.Context, roomID string, userID int64, admin bool) error {
+	const op = "ws.Hub.CanRead"
+
+	rm, err := h.Room(ctx, roomID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := h.canRead(ctx, rm, userID, admin); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CanPost implements message.AccessChecker with the rules of a message.send:
+// on top of reading, the room can't be archived and the user can't be muted.
+func (h *Hub) CanPost(ctx context.Context, roomID string, userID int64, admin bool) error {
+	const op = "ws.Hub.CanPost"
+
+	rm, err := h.Room(ctx, roomID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := h.canRead(ctx, rm, userID, admin); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if rm.Archived() {
+		return fmt.Errorf("%s: %w", op, message.ErrRoomArchived)
+	}
+	if _, muted := rm.mutedUntil(strconv.FormatInt(userID, 10)); muted {
+		return fmt.Errorf("%s: %w", op, message.ErrMuted)
+	}
+
+	return nil
+}
+
+func (h *Hub) canRead(ctx context.Context, rm *Room, userID int64, admin bool) error {
+	if !admin && rm.CreatedBy != userID {
+		_, banned, err := h.Banned(ctx, rm.RoomId, userID)
+		if err != nil {
+			return err
+		}
+		if banned {
+			return message.ErrBanned
+		}
+	}
+
+	ok, err := h.CanAccess(ctx, rm, userID, admin)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return message.ErrNoAccess
+	}
+
+	return nil
+}
+
+func (h *Hub) CreateInvite(ctx context.Context, roomID string, createdBy int64, ttl time.Duration, maxUses int) (*room.Invite, error) {
+	const op = "ws.Hub.CreateInvite"
+
+	invite := &room.Invite{
+		Code:      newInviteCode(),
+		RoomID:    roomID,
+		CreatedBy: createdBy,
+		MaxUses:   maxUses,
+	}
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		invite.ExpiresAt = &expiresAt
+	}
+
+	invite, err := h.rooms.CreateInvite(ctx, invite)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return invite, nil
+}
+
+// AcceptInvite makes the user a member of the invite's room. Members don't
+// spend a use. A non-empty roomID rejects invites to other rooms.
+func (h *Hub) AcceptInvite(ctx context.Context, code, roomID string, userID int64) (*room.Invite, error) {
+	const op = "ws.Hub.AcceptInvite"
+
+	invite, err := h.rooms.GetInvite(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if roomID != "" && invite.RoomID != roomID {
+		return nil, fmt.Errorf("%s: %w", op, room.ErrInviteNotFound)
+	}
+
+	member, err := h.rooms.IsMember(ctx, invite.RoomID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if member {
+		return invite, nil
+	}
+
+	invite, err = h.rooms.UseInvite(ctx, code, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return invite, nil
+}
+
+// DecideJoinRequest approves or rejects a join request and lets the user know
+// on every instance.
+func (h *Hub) DecideJoinRequest(ctx context.Context, rm *Room, userID, decidedBy int64, approve bool) error {
+	const op = "ws.Hub.DecideJoinRequest"
+
+	if err := h.rooms.DecideJoinRequest(ctx, rm.RoomId, userID, decidedBy, approve); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the name is read from storage, rm is only safe to read on its goroutine;
+	// the decision is made already, so a failed read only makes it vaguer
+	name := "the room"
+	if stored, err := h.rooms.GetRoomByID(ctx, rm.RoomId); err != nil {
+		log.Printf("joinRequestNoticeError: %v", err)
+	} else {
+		name = stored.Name
+	}
+
+	content := "Your request to join %s was rejected"
+	if approve {
+		content = "Your request to join %s was approved"
+	}
+	id := strconv.FormatInt(userID, 10)
+	e := newEnvelope(EventNotice, "", Notice{
+		RoomID:    rm.RoomId,
+		UserID:    id,
+		Content:   fmt.Sprintf(content, name),
+		CreatedAt: time.Now(),
+	})
+	h.deliverToUsers([]string{id}, e)
+	h.publish(&BrokerMessage{UserIDs: []string{id}, Event: e})
+
+	return nil
+}
+
+// admit makes sure the caller may access the room and isn't banned from it,
+// accepting the invite if one is given, and answers the request otherwise.
+func (h *Handler) admit(w http.ResponseWriter, r *http.Request, rm *Room, p *middleware.Principal, invite string) bool {
+	if !h.checkBan(w, r, rm, p) {
+		return false
+	}
+
+	ok, err := h.hub.CanAccess(r.Context(), rm, p.ID, p.Admin)
+	if err != nil {
+		h.Log.Error("Failed to check room access", "room_id", rm.RoomId, "error", err)
+		h.sendErrorResponse(w, "Couldn't check room access", http.StatusInternalServerError)
+		return false
+	}
+	if ok {
+		return true
+	}
+
+	if invite != "" {
+		_, err := h.hub.AcceptInvite(r.Context(), invite, rm.RoomId, p.ID)
+		if err == nil {
+			return true
+		}
+		if !h.sendInviteError(w, err) {
+			h.Log.Error("Failed to accept invite", "room_id", rm.RoomId, "error", err)
+			h.sendErrorResponse(w, "Couldn't accept the invite", http.StatusInternalServerError)
+		}
+		return false
+	}
+
+	if rm.Visibility() == room.VisibilityPrivate {
+		h.sendErrorResponse(w, "This room is private, ask the owner to let you in", http.StatusForbidden)
+		return false
+	}
+	h.sendErrorResponse(w, "This room is invite-only", http.StatusForbidden)
+	return false
+}
+
+// sendInviteError answers the request if err is about the invite itself.
+func (h *Handler) sendInviteError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, room.ErrInviteNotFound):
+		h.sendErrorResponse(w, "Invite not found", http.StatusNotFound)
+	case errors.Is(err, room.ErrInviteExpired):
+		h.sendErrorResponse(w, "Invite has expired", http.StatusGone)
+	case errors.Is(err, room.ErrInviteExhausted):
+		h.sendErrorResponse(w, "Invite has no uses left", http.StatusGone)
+	default:
+		return false
+	}
+	return true
+}
+
+// authorizeAccess makes sure the room exists and the caller may read it,
+// answering the request otherwise.
+func (h *Handler) authorizeAccess(w http.ResponseWriter, r *http.Request, roomID string) bool {
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		h.sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return false
+	}
+
+	rm, ok := h.loadRoom(w, r, roomID)
+	if !ok {
+		return false
+	}
+
+	return h.admit(w, r, rm, p, "")
+}
+
+// CreateInvite godoc
+// @Summary      Create an invite
+// @Description  Creates an invitation link to the room, optionally expiring and limited in uses. Only the owner of the room or an admin may create invites.
+// @Tags         room
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      string           true  "Room ID"
+// @Param        invite  body      CreateInviteReq  true  "Invite settings"
+// @Success      201     {object}  InviteRes
+// @Failure      400     {object}  ErrorResponse  "Bad request"
+// @Failure      401     {object}  ErrorResponse  "Unauthorized"
+// @Failure      403     {object}  ErrorResponse  "Forbidden"
+// @Failure      404     {object}  ErrorResponse  "Room not found"
+// @Failure      500     {object}  ErrorResponse  "Internal error"
+// @Router       /rooms/{id}/invites [post]
+func (h *Handler) CreateInvite(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+
+	var req CreateInviteReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendErrorResponse(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresIn < 0 || req.MaxUses < 0 {
+		h.sendErrorResponse(w, "expiresIn and maxUses can't be negative", http.StatusBadRequest)
+		return
+	}
+
+	_, p, ok := h.authorizeRoom(w, r, roomID)
+	if !ok {
+		return
+	}
+
+	invite, err := h.hub.CreateInvite(r.Context(), roomID, p.ID, time.Duration(req.ExpiresIn)*time.Second, req.MaxUses)
+	if errors.Is(err, room.ErrRoomNotFound) {
+		h.sendErrorResponse(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Log.Error("Failed to create invite", "room_id", roomID, "error", err)
+		h.sendErrorResponse(w, "Couldn't create the invite", http.StatusInternalServerError)
+		return
+	}
+
+	h.Log.Info("Invite created successfully", "room_id", roomID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(toInviteRes(invite))
+}
+
+// RevokeInvite godoc
+// @Summary      Revoke an invite
+// @Description  Deletes an invite so that it can't be used anymore. Only the owner of the room or an admin may revoke invites.
+// @Tags         room
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id    path      string  true  "Room ID"
+// @Param        code  path      string  true  "Invite code"
+// @Success      204   {string}  string  "No Content"
+// @Failure      401   {object}  ErrorResponse  "Unauthorized"
+// @Failure      403   {object}  ErrorResponse  "Forbidden"
+// @Failure      404   {object}  ErrorResponse  "Room or invite not found"
+// @Failure      500   {object}  ErrorResponse  "Internal error"
+// @Router       /rooms/{id}/invites/{code} [delete]
+func (h *Handler) RevokeInvite(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+
+	if _, _, ok := h.authorizeRoom(w, r, roomID); !ok {
+		return
+	}
+
+	err := h.hub.rooms.DeleteInvite(r.Context(), roomID, chi.URLParam(r, "code"))
+	if errors.Is(err, room.ErrInviteNotFound) {
+		h.sendErrorResponse(w, "Invite not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Log.Error("Failed to revoke invite", "room_id", roomID, "error", err)
+		h.sendErrorResponse(w, "Couldn't revoke the invite", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetInvite godoc
+// @Summary      Show an invite
+// @Description  Returns the invite behind an invitation link.
+// @Tags         room
+// @Produce      json
+// @Security     BearerAuth
+// @Param        code  path      string  true  "Invite code"
+// @Success      200   {object}  InviteRes
+// @Failure      401   {object}  ErrorResponse  "Unauthorized"
+// @Failure      404   {object}  ErrorResponse  "Invite not found"
+// @Failure      500   {object}  ErrorResponse  "Internal error"
+// @Router       /invites/{code} [get]
+func (h *Handler) GetInvite(w http.ResponseWriter, r *http.Request) {
+	invite, err := h.hub.rooms.GetInvite(r.Context(), chi.URLParam(r, "code"))
+	if err != nil {
+		if !h.sendInviteError(w, err) {
+			h.Log.Error("Failed to load invite", "error", err)
+			h.sendErrorResponse(w, "Couldn't load the invite", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toInviteRes(invite))
+}
+
+// AcceptInvite godoc
+// @Summary      Accept an invite
+// @Description  Makes the caller a member of the room the invite is for. The room can then be joined like any other; passing the code as the "invite" query parameter of the join endpoints does the same.
+// @Tags         room
+// @Produce      json
+// @Security     BearerAuth
+// @Param        code  path      string  true  "Invite code"
+// @Success      200   {object}  InviteRes
+// @Failure      401   {object}  ErrorResponse  "Unauthorized"
+// @Failure      404   {object}  ErrorResponse  "Invite not found"
+// @Failure      410   {object}  ErrorResponse  "Invite expired or used up"
+// @Failure      500   {object}  ErrorResponse  "Internal error"
+// @Router       /invites/{code}/accept [post]
+func (h *Handler) AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		h.sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	invite, err := h.hub.AcceptInvite(r.Context(), chi.URLParam(r, "code"), "", p.ID)
+	if err != nil {
+		if !h.sendInviteError(w, err) {
+			h.Log.Error("Failed to accept invite", "error", err)
+			h.sendErrorResponse(w, "Couldn't accept the invite", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.Log.Info("Invite accepted", "room_id", invite.RoomID, "user_id", p.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toInviteRes(invite))
+}
+
+// RequestJoin godoc
+// @Summary      Ask to join a private room
+// @Description  Files a join request the owner of the private room approves or rejects. Asking again while a request is pending returns it unchanged.
+// @Tags         room
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Room ID"
+// @Success      202  {object}  room.JoinRequest
+// @Failure      400  {object}  ErrorResponse  "The room isn't private"
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      403  {object}  ErrorResponse  "The room is invite-only"
+// @Failure      404  {object}  ErrorResponse  "Room not found"
+// @Failure      409  {object}  ErrorResponse  "Already a member"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /rooms/{id}/requests [post]
+func (h *Handler) RequestJoin(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		h.sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	rm, ok := h.loadRoom(w, r, roomID)
+	if !ok {
+		return
+	}
+	switch rm.Visibility() {
+	case room.VisibilityPublic:
+		h.sendErrorResponse(w, "Public rooms can be joined directly", http.StatusBadRequest)
+		return
+	case room.VisibilityInviteOnly:
+		h.sendErrorResponse(w, "This room is invite-only", http.StatusForbidden)
+		return
+	}
+
+	member, err := h.hub.rooms.IsMember(r.Context(), roomID, p.ID)
+	if err != nil {
+		h.Log.Error("Failed to check membership", "room_id", roomID, "error", err)
+		h.sendErrorResponse(w, "Couldn't file the request", http.StatusInternalServerError)
+		return
+	}
+	if member || rm.CreatedBy == p.ID {
+		h.sendErrorResponse(w, "You are already a member of this room", http.StatusConflict)
+		return
+	}
+
+	req, err := h.hub.rooms.CreateJoinRequest(r.Context(), roomID, p.ID)
+	if err != nil {
+		h.Log.Error("Failed to create join request", "room_id", roomID, "error", err)
+		h.sendErrorResponse(w, "Couldn't file the request", http.StatusInternalServerError)
+		return
+	}
+
+	h.Log.Info("Join request filed", "room_id", roomID, "user_id", p.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(req)
+}
+
+// ListJoinRequests godoc
+// @Summary      List join requests
+// @Description  Returns a page of the pending join requests of a room, oldest first. Only the owner of the room or an admin may see them.
+// @Tags         room
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      string  true   "Room ID"
+// @Param        limit   query     int     false  "Page size, 50 by default and 100 at most"
+// @Param        offset  query     int     false  "Number of requests to skip"
+// @Success      200     {object}  JoinRequestsRes
+// @Failure      400     {object}  ErrorResponse  "Bad request"
+// @Failure      401     {object}  ErrorResponse  "Unauthorized"
+// @Failure      403     {object}  ErrorResponse  "Forbidden"
+// @Failure      404     {object}  ErrorResponse  "Room not found"
+// @Failure      500     {object}  ErrorResponse  "Internal error"
+// @Router       /rooms/{id}/requests [get]
+func (h *Handler) ListJoinRequests(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+
+	q, err := parseListQuery(r)
+	if err != nil {
+		h.sendErrorResponse(w, "Invalid pagination parameters", http.StatusBadRequest)
+		return
+	}
+
+	if _, _, ok := h.authorizeRoom(w, r, roomID); !ok {
+		return
+	}
+
+	requests, hasMore, err := h.hub.rooms.ListJoinRequests(r.Context(), roomID, q)
+	if err != nil {
+		h.Log.Error("Failed to list join requests", "room_id", roomID, "error", err)
+		h.sendErrorResponse(w, "Couldn't list join requests", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(JoinRequestsRes{Requests: requests, HasMore: hasMore})
+}
+
+// ApproveJoinRequest godoc
+// @Summary      Approve a join request
+// @Description  Makes the user a member of the room and lets them know. Only the owner of the room or an admin may decide.
+// @Tags         room
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      string  true  "Room ID"
+// @Param        userId  path      int     true  "ID of the user who asked"
+// @Success      204     {string}  string  "No Content"
+// @Failure      400     {object}  ErrorResponse  "Bad request"
+// @Failure      401     {object}  ErrorResponse  "Unauthorized"
+// @Failure      403     {object}  ErrorResponse  "Forbidden"
+// @Failure      404     {object}  ErrorResponse  "Room or pending request not found"
+// @Failure      500     {object}  ErrorResponse  "Internal error"
+// @Router       /rooms/{id}/requests/{userId}/approve [post]
+func (h *Handler) ApproveJoinRequest(w http.ResponseWriter, r *http.Request) {
+	h.decideJoinRequest(w, r, true)
+}
+
+// RejectJoinRequest godoc
+// @Summary      Reject a join request
+// @Description  Turns the request down and lets the user know. Only the owner of the room or an admin may decide.
+// @Tags         room
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      string  true  "Room ID"
+// @Param        userId  path      int     true  "ID of the user who asked"
+// @Success      204     {string}  string  "No Content"
+// @Failure      400     {object}  ErrorResponse  "Bad request"
+// @Failure      401     {object}  ErrorResponse  "Unauthorized"
+// @Failure      403     {object}  ErrorResponse  "Forbidden"
+// @Failure      404     {object}  ErrorResponse  "Room or pending request not found"
+// @Failure      500     {object}  ErrorResponse  "Internal error"
+// @Router       /rooms/{id}/requests/{userId}/reject [post]
+func (h *Handler) RejectJoinRequest(w http.ResponseWriter, r *http.Request) {
+	h.decideJoinRequest(w, r, false)
+}
+
+func (h *Handler) decideJoinRequest(w http.ResponseWriter, r *http.Request, approve bool) {
+	roomID := chi.URLParam(r, "id")
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	if err != nil || userID <= 0 {
+		h.sendErrorResponse(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	rm, p, ok := h.authorizeRoom(w, r, roomID)
+	if !ok {
+		return
+	}
+
+	err = h.hub.DecideJoinRequest(r.Context(), rm, userID, p.ID, approve)
+	if errors.Is(err, room.ErrJoinRequestNotFound) {
+		h.sendErrorResponse(w, "Join request not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Log.Error("Failed to decide join request", "room_id", roomID, "user_id", userID, "error", err)
+		h.sendErrorResponse(w, "Couldn't decide the join request", http.StatusInternalServerError)
+		return
+	}
+
+	h.Log.Info("Join request decided", "room_id", roomID, "user_id", userID, "approved", approve)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,48 @@
+package ws
+
+import (
+	"HomeWork5/internal/middleware"
+	"github.com/gorilla/websocket"
+	"log"
+	"net/http"
+)
+
+// tokenSubprotocol marks the Sec-WebSocket-Protocol entry that is followed by
+// the JWT, e.g. "Sec-WebSocket-Protocol: access_token, <jwt>". Browsers can't
+// set headers on a WebSocket handshake, so this is their way to authenticate.
+const tokenSubprotocol = "access_token"
+
+func tokenFromSubprotocol(r *http.Request) string {
+	protocols := websocket.Subprotocols(r)
+	for i, p := range protocols {
+		if p == tokenSubprotocol && i+1 < len(protocols) {
+			return protocols[i+1]
+		}
+	}
+
+	return ""
+}
+
+// authenticate resolves the caller of a handshake request. The principal set by
+// middleware.AuthMiddleware wins, then the cookie/Authorization header, then the
+// subprotocol token, whose admin claim is verified like the middleware does.
+func (h *Handler) authenticate(r *http.Request) (*middleware.Principal, error) {
+	if p, ok := middleware.PrincipalFromContext(r.Context()); ok {
+		return p, nil
+	}
+
+	token := middleware.TokenFromRequest(r)
+	if token == "" {
+		token = tokenFromSubprotocol(r)
+	}
+
+	p, err := middleware.ParseToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := middleware.VerifyAdmin(r.Context(), p, h.admins); err != nil {
+		log.Printf("verifyAdminError: %v", err)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,80 @@
+package ws
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+)
+
+// BrokerMessage is an event published by one hub for the others. It targets
+// either the members of RoomID, except the connections of ExcludeUserID, or
+// every connection of UserIDs. Presence updates come without an event, every
+// hub builds its own, and Online lists the users connected to the sender.
+type BrokerMessage struct {
+	Node          string          `json:"node"`
+	RoomID        string          `json:"roomId,omitempty"`
+	ExcludeUserID string          `json:"excludeUserId,omitempty"`
+	UserIDs       []string        `json:"userIds,omitempty"`
+	Seq           int64           `json:"seq,omitempty"`
+	Event         *Envelope       `json:"event"`
+	Presence      *PresenceUpdate `json:"presence,omitempty"`
+	Online        []string        `json:"online,omitempty"`
+}
+
+// Broker carries events between hubs so that members of a room connected to
+// different server instances all get them. Subscribers also receive what they
+// published themselves; the hub skips its own node.
+type Broker interface {
+	Publish(ctx context.Context, m *BrokerMessage) error
+	// Subscribe calls deliver for every published message until ctx is done.
+	Subscribe(ctx context.Context, deliver func(*BrokerMessage)) error
+}
+
+// LocalBroker connects hubs living in the same process. With a single hub,
+// which is the default setup, it does nothing.
+type LocalBroker struct {
+	mu   sync.RWMutex
+	next int
+	subs map[int]func(*BrokerMessage)
+}
+
+func NewLocalBroker() *LocalBroker {
+	return &LocalBroker{subs: make(map[int]func(*BrokerMessage))}
+}
+
+func (b *LocalBroker) Publish(ctx context.Context, m *BrokerMessage) error {
+	b.mu.RLock()
+	subs := make([]func(*BrokerMessage), 0, len(b.subs))
+	for _, deliver := range b.subs {
+		subs = append(subs, deliver)
+	}
+	b.mu.RUnlock()
+
+	for _, deliver := range subs {
+		deliver(m)
+	}
+	return nil
+}
+
+func (b *LocalBroker) Subscribe(ctx context.Context, deliver func(*BrokerMessage)) error {
+	b.mu.Lock()
+	id := b.next
+	b.next++
+	b.subs[id] = deliver
+	b.mu.Unlock()
+
+	<-ctx.Done()
+
+	b.mu.Lock()
+	delete(b.subs, id)
+	b.mu.Unlock()
+
+	return nil
+}
+
+func newNodeID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
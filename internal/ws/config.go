@@ -0,0 +1,143 @@
+package ws
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// OverflowPolicy decides what happens when a connection's send queue is full.
+type OverflowPolicy string
+
+const (
+	// DropOldest discards the oldest queued message to make room.
+	DropOldest OverflowPolicy = "drop_oldest"
+	// DropNewest discards the message being sent.
+	DropNewest OverflowPolicy = "drop_newest"
+	// Disconnect closes the connection with ClosePolicyViolation.
+	Disconnect OverflowPolicy = "disconnect"
+)
+
+// BrokerKind selects how hubs on different instances exchange events.
+type BrokerKind string
+
+const (
+	// BrokerLocal keeps events inside the process, for a single instance.
+	BrokerLocal BrokerKind = "local"
+	// BrokerPostgres uses PostgreSQL LISTEN/NOTIFY between instances.
+	BrokerPostgres BrokerKind = "postgres"
+)
+
+type Config struct {
+	// SendQueueSize bounds the messages buffered per connection. Keep it above
+	// message.MaxLimit so that a history replay fits in.
+	SendQueueSize  int
+	OverflowPolicy OverflowPolicy
+
+	// PingInterval is how often the server pings a client. It must be shorter
+	// than PongWait, the time a client has to answer before it's dropped.
+	PingInterval time.Duration
+	PongWait     time.Duration
+	// WriteTimeout bounds a single frame write to the client.
+	WriteTimeout time.Duration
+	// MaxMessageSize is the largest frame in bytes accepted from a client.
+	MaxMessageSize int64
+
+	// TypingInterval is the shortest time between two typing.start events of
+	// a user fanned out to the room; TypingTimeout is how long a user counts
+	// as typing without another start before the server sends typing.stop.
+	TypingInterval time.Duration
+	TypingTimeout  time.Duration
+
+	// PresenceInterval is how often an instance republishes its connected
+	// users to the others, which count them offline after two intervals
+	// without a report.
+	PresenceInterval time.Duration
+
+	Broker BrokerKind
+}
+
+func DefaultConfig() Config {
+	return Config{
+		SendQueueSize:    256,
+		OverflowPolicy:   DropOldest,
+		PingInterval:     54 * time.Second,
+		PongWait:         60 * time.Second,
+		WriteTimeout:     10 * time.Second,
+		MaxMessageSize:   32 * 1024,
+		TypingInterval:   3 * time.Second,
+		TypingTimeout:    6 * time.Second,
+		PresenceInterval: 30 * time.Second,
+		Broker:           BrokerLocal,
+	}
+}
+
+// ConfigFromEnv overrides the defaults with WS_SEND_QUEUE_SIZE,
+// WS_OVERFLOW_POLICY, WS_PING_INTERVAL, WS_PONG_WAIT, WS_WRITE_TIMEOUT,
+// WS_MAX_MESSAGE_SIZE, WS_TYPING_INTERVAL, WS_TYPING_TIMEOUT,
+// WS_PRESENCE_INTERVAL and WS_BROKER when they are set. Durations use time.ParseDuration syntax, e.g. "30s".
+func ConfigFromEnv() (Config, error) {
+	const op = "ws.ConfigFromEnv"
+	cfg := DefaultConfig()
+
+	if v := os.Getenv("WS_SEND_QUEUE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return cfg, fmt.Errorf("%s: invalid WS_SEND_QUEUE_SIZE %q", op, v)
+		}
+		cfg.SendQueueSize = n
+	}
+
+	if v := os.Getenv("WS_OVERFLOW_POLICY"); v != "" {
+		switch p := OverflowPolicy(v); p {
+		case DropOldest, DropNewest, Disconnect:
+			cfg.OverflowPolicy = p
+		default:
+			return cfg, fmt.Errorf("%s: invalid WS_OVERFLOW_POLICY %q", op, v)
+		}
+	}
+
+	for name, dst := range map[string]*time.Duration{
+		"WS_PING_INTERVAL":     &cfg.PingInterval,
+		"WS_PONG_WAIT":         &cfg.PongWait,
+		"WS_WRITE_TIMEOUT":     &cfg.WriteTimeout,
+		"WS_TYPING_INTERVAL":   &cfg.TypingInterval,
+		"WS_TYPING_TIMEOUT":    &cfg.TypingTimeout,
+		"WS_PRESENCE_INTERVAL": &cfg.PresenceInterval,
+	} {
+		if v := os.Getenv(name); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				return cfg, fmt.Errorf("%s: invalid %s %q", op, name, v)
+			}
+			*dst = d
+		}
+	}
+
+	if v := os.Getenv("WS_MAX_MESSAGE_SIZE"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 {
+			return cfg, fmt.Errorf("%s: invalid WS_MAX_MESSAGE_SIZE %q", op, v)
+		}
+		cfg.MaxMessageSize = n
+	}
+
+	if v := os.Getenv("WS_BROKER"); v != "" {
+		switch b := BrokerKind(v); b {
+		case BrokerLocal, BrokerPostgres:
+			cfg.Broker = b
+		default:
+			return cfg, fmt.Errorf("%s: invalid WS_BROKER %q", op, v)
+		}
+	}
+
+	if cfg.PingInterval >= cfg.PongWait {
+		return cfg, fmt.Errorf("%s: WS_PING_INTERVAL must be shorter than WS_PONG_WAIT", op)
+	}
+	if cfg.TypingInterval >= cfg.TypingTimeout {
+		return cfg, fmt.Errorf("%s: WS_TYPING_INTERVAL must be shorter than WS_TYPING_TIMEOUT", op)
+	}
+
+	return cfg, nil
+}
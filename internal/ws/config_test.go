@@ -0,0 +1,69 @@
+package ws
+
+import "testing"
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+	}{
+		{name: "defaults"},
+		{
+			name: "ping shorter than pong wait",
+			env:  map[string]string{"WS_PING_INTERVAL": "5s", "WS_PONG_WAIT": "10s"},
+		},
+		{
+			name:    "ping equal to pong wait",
+			env:     map[string]string{"WS_PING_INTERVAL": "10s", "WS_PONG_WAIT": "10s"},
+			wantErr: true,
+		},
+		{
+			name:    "ping longer than the default pong wait",
+			env:     map[string]string{"WS_PING_INTERVAL": "2m"},
+			wantErr: true,
+		},
+		{
+			name: "typing interval shorter than timeout",
+			env:  map[string]string{"WS_TYPING_INTERVAL": "1s", "WS_TYPING_TIMEOUT": "2s"},
+		},
+		{
+			name:    "typing interval equal to timeout",
+			env:     map[string]string{"WS_TYPING_INTERVAL": "2s", "WS_TYPING_TIMEOUT": "2s"},
+			wantErr: true,
+		},
+		{
+			name:    "typing timeout below the default interval",
+			env:     map[string]string{"WS_TYPING_TIMEOUT": "1s"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid duration",
+			env:     map[string]string{"WS_PONG_WAIT": "soon"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid overflow policy",
+			env:     map[string]string{"WS_OVERFLOW_POLICY": "block"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{
+				"WS_SEND_QUEUE_SIZE", "WS_OVERFLOW_POLICY", "WS_PING_INTERVAL",
+				"WS_PONG_WAIT", "WS_WRITE_TIMEOUT", "WS_MAX_MESSAGE_SIZE",
+				"WS_TYPING_INTERVAL", "WS_TYPING_TIMEOUT", "WS_PRESENCE_INTERVAL",
+				"WS_BROKER",
+			} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			_, err := ConfigFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConfigFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+package ws
+
+import (
+	"HomeWork5/internal/message"
+	"HomeWork5/internal/middleware"
+	"HomeWork5/internal/room"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+var (
+	ErrForbidden = errors.New("not allowed to change the message")
+	ErrMuted     = message.ErrMuted
+)
+
+// MessageHistoryRes is a message with its previous versions, oldest first.
+type MessageHistoryRes struct {
+	Message *Message        `json:"message"`
+	Edits   []*message.Edit `json:"edits"`
+}
+
+// authorizeChange makes sure the user may change the message: authors change
+// their own messages, moderators any message of their room.
+func (h *Hub) authorizeChange(ctx context.Context, m *message.Message, userID int64, admin bool) (*Room, error) {
+	rm, err := h.Room(ctx, m.RoomID)
+	if err != nil {
+		return nil, err
+	}
+	if m.UserID == userID {
+		return rm, nil
+	}
+
+	role, err := h.Role(ctx, rm, userID, admin)
+	if err != nil {
+		return nil, err
+	}
+	if role.Rank() < room.RoleModerator.Rank() {
+		return nil, ErrForbidden
+	}
+	return rm, nil
+}
+
+// EditMessage replaces the content of a stored message and lets the room know
+// on every instance.
+func (h *Hub) EditMessage(ctx context.Context, m *message.Message, userID int64, admin bool, content string) (*Message, error) {
+	const op = "ws.Hub.EditMessage"
+
+	if err := message.ValidateContent(content); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if m.DeletedAt != nil {
+		return nil, fmt.Errorf("%s: %w", op, message.ErrMessageDeleted)
+	}
+
+	rm, err := h.authorizeChange(ctx, m, userID, admin)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if rm.Archived() {
+		return nil, fmt.Errorf("%s: %w", op, room.ErrRoomArchived)
+	}
+	if _, muted := rm.mutedUntil(strconv.FormatInt(userID, 10)); muted {
+		return nil, fmt.Errorf("%s: %w", op, ErrMuted)
+	}
+
+	stored, err := h.messages.UpdateMessage(ctx, m.ID, userID, content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	updated := toMessage(stored)
+	h.broadcastEvent(updated.RoomID, newEnvelope(EventMessageUpdated, "", updated))
+
+	return updated, nil
+}
+
+// DeleteMessage turns a stored message into a tombstone and lets the room
+// know on every instance.
+func (h *Hub) DeleteMessage(ctx context.Context, m *message.Message, userID int64, admin bool) (*Message, error) {
+	const op = "ws.Hub.DeleteMessage"
+
+	if m.DeletedAt != nil {
+		return nil, fmt.Errorf("%s: %w", op, message.ErrMessageDeleted)
+	}
+
+	rm, err := h.authorizeChange(ctx, m, userID, admin)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if rm.Archived() {
+		return nil, fmt.Errorf("%s: %w", op, room.ErrRoomArchived)
+	}
+
+	stored, err := h.messages.DeleteMessage(ctx, m.ID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted := toMessage(stored)
+	h.broadcastEvent(deleted.RoomID, newEnvelope(EventMessageDeleted, "", deleted))
+
+	return deleted, nil
+}
+
+// MessageHistory returns the previous versions of a message, including the
+// content of deleted ones, to its author and the moderators of its room.
+func (h *Hub) MessageHistory(ctx context.Context, m *message.Message, userID int64, admin bool) (*MessageHistoryRes, error) {
+	const op = "ws.Hub.MessageHistory"
+
+	if _, err := h.authorizeChange(ctx, m, userID, admin); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	edits, err := h.messages.ListEdits(ctx, m.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &MessageHistoryRes{Message: toMessage(m), Edits: edits}, nil
+}
+
+func (u *User) handleEdit(h *Hub, e *Envelope) {
+	var p EditPayload
+	if err := json.Unmarshal(e.Payload, &p); err != nil || p.MessageID <= 0 {
+		u.send(errorEvent(e.ID, ErrCodeInvalidPayload, "payload must be {\"messageId\": number, \"content\": string}"))
+		return
+	}
+
+	userID, err := strconv.ParseInt(u.ID, 10, 64)
+	if err != nil {
+		u.send(errorEvent(e.ID, ErrCodeInternal, "couldn't change the message"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+
+	m, err := h.messages.GetMessage(ctx, p.MessageID)
+	if err == nil && m.RoomID != u.RoomID {
+		err = message.ErrMessageNotFound
+	}
+
+	var changed *Message
+	if err == nil {
+		if e.Type == EventMessageEdit {
+			changed, err = h.EditMessage(ctx, m, userID, u.admin, p.Content)
+		} else {
+			changed, err = h.DeleteMessage(ctx, m, userID, u.admin)
+		}
+	}
+
+	switch {
+	case errors.Is(err, message.ErrEmptyContent), errors.Is(err, message.ErrContentTooLong):
+		u.send(errorEvent(e.ID, ErrCodeInvalidPayload, err.Error()))
+	case errors.Is(err, message.ErrMessageNotFound):
+		u.send(errorEvent(e.ID, ErrCodeNotFound, "message not found"))
+	case errors.Is(err, message.ErrMessageDeleted):
+		u.send(errorEvent(e.ID, ErrCodeNotFound, "the message was deleted"))
+	case errors.Is(err, ErrForbidden):
+		u.send(errorEvent(e.ID, ErrCodeForbidden, "only the author or a moderator can change the message"))
+	case errors.Is(err, ErrMuted):
+		u.send(errorEvent(e.ID, ErrCodeMuted, "you are muted in this room"))
+	case errors.Is(err, room.ErrRoomArchived):
+		u.send(errorEvent(e.ID, ErrCodeRoomArchived, "the room is archived"))
+	case err != nil:
+		log.Printf("editMessageError: %v", err)
+		u.send(errorEvent(e.ID, ErrCodeInternal, "couldn't change the message"))
+	default:
+		u.send(newEnvelope(EventMessageAck, e.ID, AckPayload{MessageID: changed.ID, CreatedAt: changed.CreatedAt}))
+	}
+}
+
+// loadMessage returns the message in the path once the caller is known to
+// have access to its room, answering the request otherwise.
+func (h *Handler) loadMessage(w http.ResponseWriter, r *http.Request) (*message.Message, *middleware.Principal, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || id <= 0 {
+		h.sendErrorResponse(w, "Invalid message id", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		h.sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	m, err := h.hub.messages.GetMessage(r.Context(), id)
+	if errors.Is(err, message.ErrMessageNotFound) {
+		h.sendErrorResponse(w, "Message not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	if err != nil {
+		h.Log.Error("Failed to load message", "message_id", id, "error", err)
+		h.sendErrorResponse(w, "Couldn't load the message", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	if !h.authorizeAccess(w, r, m.RoomID) {
+		return nil, nil, false
+	}
+
+	return m, p, true
+}
+
+// sendChangeError answers a failed edit or deletion.
+func (h *Handler) sendChangeError(w http.ResponseWriter, m *message.Message, err error) {
+	switch {
+	case errors.Is(err, message.ErrEmptyContent):
+		h.sendErrorResponse(w, "Message content is required", http.StatusBadRequest)
+	case errors.Is(err, message.ErrContentTooLong):
+		h.sendErrorResponse(w, fmt.Sprintf("Message is longer than %d characters", message.MaxContentLength), http.StatusBadRequest)
+	case errors.Is(err, ErrForbidden):
+		h.sendErrorResponse(w, "Only the author or a moderator can change this message", http.StatusForbidden)
+	case errors.Is(err, ErrMuted):
+		h.sendErrorResponse(w, "You are muted in this room", http.StatusForbidden)
+	case errors.Is(err, message.ErrMessageNotFound):
+		h.sendErrorResponse(w, "Message not found", http.StatusNotFound)
+	case errors.Is(err, message.ErrMessageDeleted):
+		h.sendErrorResponse(w, "The message was deleted", http.StatusGone)
+	case errors.Is(err, room.ErrRoomArchived):
+		h.sendErrorResponse(w, "The room is archived", http.StatusConflict)
+	default:
+		h.Log.Error("Failed to change message", "message_id", m.ID, "room_id", m.RoomID, "error", err)
+		h.sendErrorResponse(w, "Couldn't change the message", http.StatusInternalServerError)
+	}
+}
+
+// EditMessage godoc
+// @Summary      Edit a message
+// @Description  Replaces the content of a room message. The previous content is kept in the message history and members of the room get a "message.updated" event. Authors may edit their own messages, moderators any message of their room.
+// @Tags         message
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      int                 true  "Message ID"
+// @Param        message  body      message.MessageReq  true  "New content"
+// @Success      200      {object}  Message
+// @Failure      400      {object}  ErrorResponse  "Bad request"
+// @Failure      401      {object}  ErrorResponse  "Unauthorized"
+// @Failure      403      {object}  ErrorResponse  "Forbidden"
+// @Failure      404      {object}  ErrorResponse  "Message not found"
+// @Failure      409      {object}  ErrorResponse  "The room is archived"
+// @Failure      410      {object}  ErrorResponse  "The message was deleted"
+// @Failure      500      {object}  ErrorResponse  "Internal error"
+// @Router       /messages/{id} [patch]
+func (h *Handler) EditMessage(w http.ResponseWriter, r *http.Request) {
+	m, p, ok := h.loadMessage(w, r)
+	if !ok {
+		return
+	}
+
+	var req message.MessageReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := h.hub.EditMessage(r.Context(), m, p.ID, p.Admin, req.Content)
+	if err != nil {
+		h.sendChangeError(w, m, err)
+		return
+	}
+
+	h.Log.Info("Message edited", "message_id", m.ID, "room_id", m.RoomID, "user_id", p.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteMessage godoc
+// @Summary      Delete a message
+// @Description  Turns a room message into a tombstone without content; the content is kept in the message history. Members of the room get a "message.deleted" event. Authors may delete their own messages, moderators any message of their room.
+// @Tags         message
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "Message ID"
+// @Success      204  {string}  string  "No Content"
+// @Failure      400  {object}  ErrorResponse  "Bad request"
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      403  {object}  ErrorResponse  "Forbidden"
+// @Failure      404  {object}  ErrorResponse  "Message not found"
+// @Failure      409  {object}  ErrorResponse  "The room is archived"
+// @Failure      410  {object}  ErrorResponse  "The message was already deleted"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /messages/{id} [delete]
+func (h *Handler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	m, p, ok := h.loadMessage(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.hub.DeleteMessage(r.Context(), m, p.ID, p.Admin); err != nil {
+		h.sendChangeError(w, m, err)
+		return
+	}
+
+	h.Log.Info("Message deleted", "message_id", m.ID, "room_id", m.RoomID, "user_id", p.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMessageHistory godoc
+// @Summary      Get the edit history of a message
+// @Description  Returns a message with its previous versions, oldest first, including the last content of a deleted message. Only the author and the moderators of the room may see it.
+// @Tags         message
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "Message ID"
+// @Success      200  {object}  MessageHistoryRes
+// @Failure      400  {object}  ErrorResponse  "Bad request"
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      403  {object}  ErrorResponse  "Forbidden"
+// @Failure      404  {object}  ErrorResponse  "Message not found"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /messages/{id}/history [get]
+func (h *Handler) GetMessageHistory(w http.ResponseWriter, r *http.Request) {
+	m, p, ok := h.loadMessage(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := h.hub.MessageHistory(r.Context(), m, p.ID, p.Admin)
+	if errors.Is(err, ErrForbidden) {
+		h.sendErrorResponse(w, "Only the author or a moderator can see the history of this message", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		h.Log.Error("Failed to load message history", "message_id", m.ID, "error", err)
+		h.sendErrorResponse(w, "Couldn't load the message history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
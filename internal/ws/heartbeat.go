@@ -0,0 +1,40 @@
+package ws
+
+import (
+	"errors"
+	"github.com/gorilla/websocket"
+	"net"
+	"time"
+)
+
+// The heartbeat keeps half-open connections from lingering in a room: the
+// writer pings every PingInterval, each pong (or frame) from the client pushes
+// the read deadline PongWait further, and a missed deadline fails the read,
+// which unregisters the user through Hub.Unregister.
+
+// prepareRead applies the read limit and arms the read deadline.
+func (u *User) prepareRead() {
+	u.Con.SetReadLimit(u.cfg.MaxMessageSize)
+	u.extendReadDeadline()
+	u.Con.SetPongHandler(func(string) error {
+		u.extendReadDeadline()
+		return nil
+	})
+}
+
+func (u *User) extendReadDeadline() {
+	u.Con.SetReadDeadline(time.Now().Add(u.cfg.PongWait))
+}
+
+func (u *User) writeDeadline() time.Time {
+	return time.Now().Add(u.cfg.WriteTimeout)
+}
+
+func (u *User) ping() error {
+	return u.Con.WriteControl(websocket.PingMessage, nil, u.writeDeadline())
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
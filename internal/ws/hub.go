@@ -1,79 +1,737 @@
 package ws
 
-import "fmt"
+import (
+	"HomeWork5/internal/message"
+	"HomeWork5/internal/presence"
+	"HomeWork5/internal/room"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
 
-type Room struct {
-	RoomId string           `json:"roomId"`
-	Name   string           `json:"name"`
-	Users  map[string]*User `json:"users"`
-}
+const (
+	// storageTimeout bounds storage calls made on behalf of a connection.
+	storageTimeout = 5 * time.Second
+
+	// shardCount spreads loaded rooms over independently locked maps so that
+	// lookups for thousands of rooms don't contend on a single lock.
+	shardCount = 32
+)
+
+var ErrHubClosed = errors.New("hub is closed")
 
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
+type roomShard struct {
+	mu    sync.RWMutex
+	rooms map[string]*Room
+}
+
+// Hub keeps the rooms loaded from storage, each served by its own goroutine,
+// and an index of connections by user id for direct delivery and presence.
 type Hub struct {
-	Rooms      map[string]*Room
-	Register   chan *User
-	Unregister chan *User
-	Broadcast  chan *Message
+	shards [shardCount]*roomShard
+
+	clientsMu sync.RWMutex
+	clients   map[string]map[*User]bool
+
+	// remote maps user ids to the other instances they are connected to and
+	// when those last reported them.
+	presenceMu    sync.RWMutex
+	remote        map[string]map[string]time.Time
+	presenceLocks [shardCount]sync.Mutex
+
+	rooms    room.Repository
+	messages message.Repository
+	presence presence.Repository
+	broker   Broker
+	node     string
+	cfg      Config
+
+	// lifecycle orders room goroutine starts against shutdown in Run.
+	lifecycle sync.RWMutex
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+func NewHub(rooms room.Repository, messages message.Repository, presences presence.Repository, broker Broker, cfg Config) *Hub {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	h := &Hub{
+		clients:  make(map[string]map[*User]bool),
+		remote:   make(map[string]map[string]time.Time),
+		rooms:    rooms,
+		messages: messages,
+		presence: presences,
+		broker:   broker,
+		node:     newNodeID(),
+		cfg:      cfg,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	for i := range h.shards {
+		h.shards[i] = &roomShard{rooms: make(map[string]*Room)}
+	}
+
+	return h
 }
 
-func NewHub() *Hub {
-	return &Hub{
-		Rooms:      make(map[string]*Room),
-		Register:   make(chan *User),
-		Unregister: make(chan *User),
-		Broadcast:  make(chan *Message),
+// Run delivers events published by other instances until ctx is cancelled,
+// then stops every room goroutine, disconnecting their members, and waits for
+// them to exit.
+func (h *Hub) Run(ctx context.Context) {
+	subscribed := make(chan struct{})
+	go func() {
+		defer close(subscribed)
+		if err := h.broker.Subscribe(h.ctx, h.receive); err != nil {
+			log.Printf("brokerSubscribeError: %v", err)
+		}
+	}()
+
+	h.wg.Add(1)
+	go h.runPresence()
+
+	select {
+	case <-ctx.Done():
+	case <-h.ctx.Done():
 	}
+
+	h.lifecycle.Lock()
+	h.cancel()
+	h.lifecycle.Unlock()
+
+	h.wg.Wait()
+	<-subscribed
+}
+
+func (h *Hub) shard(id string) *roomShard {
+	f := fnv.New32a()
+	f.Write([]byte(id))
+	return h.shards[f.Sum32()%shardCount]
+}
+
+func (h *Hub) lookup(id string) (*Room, bool) {
+	s := h.shard(id)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.rooms[id]
+	return r, ok
 }
 
-func (h *Hub) Run() {
+// add makes a freshly loaded room available and starts its goroutine, unless
+// another request got there first, in which case that room is returned.
+func (h *Hub) add(stored *room.Room, muted map[int64]*time.Time) (*Room, error) {
+	h.lifecycle.RLock()
+	defer h.lifecycle.RUnlock()
+
+	if h.ctx.Err() != nil {
+		return nil, ErrHubClosed
+	}
+
+	s := h.shard(stored.ID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r, ok := s.rooms[stored.ID]; ok {
+		return r, nil
+	}
+
+	r := newRoom(stored)
+	for userID, until := range muted {
+		r.muted[strconv.FormatInt(userID, 10)] = until
+	}
+	s.rooms[r.RoomId] = r
+	h.wg.Add(1)
+	go r.run(h.ctx, h)
+
+	return r, nil
+}
+
+// evict drops an idle room from memory; called by the room goroutine itself.
+func (h *Hub) evict(r *Room) {
+	s := h.shard(r.RoomId)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.rooms[r.RoomId] == r {
+		delete(s.rooms, r.RoomId)
+	}
+}
+
+// Room returns the room with the given id, loading it from storage the first
+// time it is requested.
+func (h *Hub) Room(ctx context.Context, id string) (*Room, error) {
+	const op = "ws.Hub.Room"
+
+	if r, ok := h.lookup(id); ok {
+		return r, nil
+	}
+
+	stored, err := h.rooms.GetRoomByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	muted, err := h.rooms.MutedUsers(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	r, err := h.add(stored, muted)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return r, nil
+}
+
+func (h *Hub) CreateRoom(ctx context.Context, req *room.Room) (*Room, error) {
+	const op = "ws.Hub.CreateRoom"
+
+	stored, err := h.rooms.CreateRoom(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if stored.CreatedBy != 0 {
+		if err := h.rooms.AddMember(ctx, stored.ID, stored.CreatedBy, room.RoleOwner); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	r, err := h.add(stored, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return r, nil
+}
+
+// UpdateRoom changes the settings of a room and lets its members know.
+func (h *Hub) UpdateRoom(ctx context.Context, id string, upd room.Update) (*room.Room, error) {
+	const op = "ws.Hub.UpdateRoom"
+
+	stored, err := h.rooms.UpdateRoom(ctx, id, upd)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	h.applyRoom(stored)
+	h.broadcastEvent(id, newEnvelope(EventRoomUpdated, "", stored))
+
+	return stored, nil
+}
+
+// applyRoom refreshes the settings of the room if it is loaded here.
+func (h *Hub) applyRoom(stored *room.Room) {
+	r, ok := h.lookup(stored.ID)
+	if !ok {
+		return
+	}
+
+	select {
+	case r.update <- stored:
+	case <-r.done:
+	}
+}
+
+// archived reports whether the room is read-only. Rooms that aren't loaded
+// have no members sending to them.
+func (h *Hub) archived(roomID string) bool {
+	r, ok := h.lookup(roomID)
+	return ok && r.Archived()
+}
+
+// DeleteRoom removes a room and disconnects its members on every instance.
+func (h *Hub) DeleteRoom(ctx context.Context, id string) error {
+	const op = "ws.Hub.DeleteRoom"
+
+	if err := h.rooms.DeleteRoom(ctx, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	e := newEnvelope(EventRoomDeleted, "", RoomDeletedPayload{RoomID: id})
+	h.closeRoom(id, e)
+	h.publish(&BrokerMessage{RoomID: id, Event: e})
+
+	return nil
+}
+
+// closeRoom tells the local members of a deleted room about it, disconnects
+// them and drops the room.
+func (h *Hub) closeRoom(roomID string, e *Envelope) {
+	r, ok := h.lookup(roomID)
+	if !ok {
+		return
+	}
+
+	select {
+	case r.remove <- e:
+	case <-r.done:
+	}
+}
+
+// Register adds the user to its room. The room may be evicted between the
+// lookup and the hand-off, in which case it's loaded again.
+func (h *Hub) Register(ctx context.Context, u *User) error {
+	const op = "ws.Hub.Register"
+
 	for {
+		r, err := h.Room(ctx, u.RoomID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
 		select {
-		case user := <-h.Register:
-			if r, ok := h.Rooms[user.RoomID]; ok {
-				r.registerUserInRoom(user)
+		case r.register <- u:
+			if h.addClient(u) {
+				h.connectionsChanged(u.ID)
 			}
-		case user := <-h.Unregister:
-			if r, ok := h.Rooms[user.RoomID]; ok {
-				if msg := r.unregisterUserInRoom(user); msg != nil {
-					h.Broadcast <- msg
-				}
-			}
-		case message := <-h.Broadcast:
-			if r, ok := h.Rooms[message.RoomID]; ok {
-				r.broadcastToUserRoom(message)
+			return nil
+		case <-r.done:
+			if h.ctx.Err() != nil {
+				return fmt.Errorf("%s: %w", op, ErrHubClosed)
 			}
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w", op, ctx.Err())
 		}
 	}
 }
 
-func (r *Room) registerUserInRoom(u *User) {
-	if _, ok := r.Users[u.ID]; !ok {
-		r.Users[u.ID] = u
+// Join records the user as a member of the room, registers it and lets the
+// room know.
+func (h *Hub) Join(ctx context.Context, u *User) error {
+	const op = "ws.Hub.Join"
+
+	userID, err := strconv.ParseInt(u.ID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
+	if err := h.rooms.AddMember(ctx, u.RoomID, userID, room.RoleMember); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := h.Register(ctx, u); err != nil {
+		return err
+	}
+	h.broadcastEvent(u.RoomID, noticeEvent(u, "%s has joined the group"))
+
+	return nil
 }
 
-func (r *Room) unregisterUserInRoom(u *User) *Message {
-	if _, ok := r.Users[u.ID]; !ok {
-		delete(r.Users, u.ID)
+// Unregister removes the user from its room and closes its outbound queue.
+func (h *Hub) Unregister(u *User) {
+	if h.removeClient(u) {
+		defer h.connectionsChanged(u.ID)
 	}
-	close(u.Message)
 
-	if len(r.Users) != 0 {
-		return &Message{
-			Content:  fmt.Sprintf("%s has left the group"),
-			RoomID:   r.RoomId,
-			Username: u.Username,
+	if r, ok := h.lookup(u.RoomID); ok {
+		select {
+		case r.unregister <- u:
+		case <-r.done:
+		}
+		// whoever leaves stops typing with their last connection
+		if !r.connected(u.ID) && r.typing.stop(u.ID) {
+			h.broadcastEvent(u.RoomID, typingEvent(EventTypingStop, u))
 		}
 	}
 
-	return nil
+	u.close()
+}
+
+// Broadcast delivers a chat message to the members of its room, replies as
+// thread.reply events, and notifies the users it mentions.
+func (h *Hub) Broadcast(m *Message) {
+	if len(m.Mentions) > 0 {
+		defer h.notifyMentions(m)
+	}
+
+	if m.ParentID != 0 {
+		h.broadcastReply(m)
+		return
+	}
+	h.broadcastEvent(m.RoomID, messageEvent(m))
+}
+
+// broadcastReply sends a reply along with the reply count of its parent.
+func (h *Hub) broadcastReply(m *Message) {
+	ctx, cancel := context.WithTimeout(h.ctx, storageTimeout)
+	defer cancel()
+
+	h.broadcastEvent(m.RoomID, replyEvent(m, h.replyCount(ctx, m.ParentID)))
+}
+
+// replyCount returns the number of live replies to the message, 0 when it
+// can't be loaded.
+func (h *Hub) replyCount(ctx context.Context, parentID int64) int {
+	parent, err := h.messages.GetMessage(ctx, parentID)
+	if err != nil {
+		log.Printf("threadReplyError: %v", err)
+		return 0
+	}
+	return parent.ReplyCount
 }
 
-func (r *Room) broadcastToUserRoom(message *Message) {
-	for _, u := range r.Users {
-		u.Message <- message
+// notifyMentions sends a mention event to every connection of the users
+// mentioned in the message, whichever room they are in.
+func (h *Hub) notifyMentions(m *Message) {
+	ctx, cancel := context.WithTimeout(h.ctx, storageTimeout)
+	defer cancel()
+
+	mentioned, err := h.messages.MentionedUsers(ctx, m.ID)
+	if err != nil {
+		log.Printf("mentionError: %v", err)
+		return
+	}
+	if len(mentioned) == 0 {
+		return
+	}
+
+	userIDs := make([]string, 0, len(mentioned))
+	for _, id := range mentioned {
+		userIDs = append(userIDs, strconv.FormatInt(id, 10))
+	}
+
+	e := newEnvelope(EventMention, "", m)
+	h.deliverToUsers(userIDs, e)
+	h.publish(&BrokerMessage{UserIDs: userIDs, Event: e})
+}
+
+// broadcastEvent delivers an event to the members of a room on this and every
+// other instance.
+func (h *Hub) broadcastEvent(roomID string, e *Envelope) {
+	h.deliverToRoom(roomID, e)
+	h.publish(&BrokerMessage{RoomID: roomID, Seq: e.seq, ExcludeUserID: e.excludeUserID, Event: e})
+}
+
+// deliverToRoom hands an event to the local members of a room. Rooms that
+// aren't loaded have no members here, so there is nothing to do for them.
+func (h *Hub) deliverToRoom(roomID string, e *Envelope) {
+	r, ok := h.lookup(roomID)
+	if !ok {
+		return
+	}
+
+	select {
+	case r.broadcast <- e:
+	case <-r.done:
+	}
+}
+
+// addClient indexes the connection and reports whether it's the first one of
+// the user.
+func (h *Hub) addClient(u *User) bool {
+	h.clientsMu.Lock()
+	defer h.clientsMu.Unlock()
+
+	conns, ok := h.clients[u.ID]
+	if !ok {
+		conns = make(map[*User]bool)
+		h.clients[u.ID] = conns
+	}
+	conns[u] = true
+	return !ok
+}
+
+// removeClient reports whether the connection was the last one of the user.
+func (h *Hub) removeClient(u *User) bool {
+	h.clientsMu.Lock()
+	defer h.clientsMu.Unlock()
+
+	conns, ok := h.clients[u.ID]
+	if !ok || !conns[u] {
+		return false
+	}
+	delete(conns, u)
+	if len(conns) == 0 {
+		delete(h.clients, u.ID)
+		return true
 	}
+	return false
+}
+
+func (h *Hub) deliverToUsers(userIDs []string, e *Envelope) {
+	for _, u := range h.connections(userIDs...) {
+		u.send(e)
+	}
+}
+
+func (h *Hub) publish(m *BrokerMessage) {
+	m.Node = h.node
+
+	ctx, cancel := context.WithTimeout(h.ctx, storageTimeout)
+	defer cancel()
+
+	if err := h.broker.Publish(ctx, m); err != nil {
+		log.Printf("brokerPublishError: %v", err)
+	}
+}
+
+// receive delivers an event published by another instance.
+func (h *Hub) receive(m *BrokerMessage) {
+	if m.Node == h.node {
+		return
+	}
+	if m.Online != nil {
+		h.refreshPresence(m.Node, m.Online)
+		return
+	}
+	if m.Presence != nil {
+		h.applyPresence(m.Node, m.Presence, m.UserIDs)
+		return
+	}
+	if m.Event == nil {
+		return
+	}
+	m.Event.seq = m.Seq
+	m.Event.excludeUserID = m.ExcludeUserID
+
+	switch m.Event.Type {
+	case EventRoomUpdated:
+		var stored room.Room
+		if err := json.Unmarshal(m.Event.Payload, &stored); err != nil {
+			log.Printf("brokerReceiveError: %v", err)
+			return
+		}
+		h.applyRoom(&stored)
+	case EventRoomDeleted:
+		h.closeRoom(m.RoomID, m.Event)
+		return
+	case EventModeration:
+		var entry room.ModerationEntry
+		if err := json.Unmarshal(m.Event.Payload, &entry); err != nil {
+			log.Printf("brokerReceiveError: %v", err)
+			return
+		}
+		h.deliverToRoom(m.RoomID, m.Event)
+		h.applyModeration(&entry)
+		return
+	}
+
+	if m.RoomID != "" {
+		h.deliverToRoom(m.RoomID, m.Event)
+	}
+	if len(m.UserIDs) > 0 {
+		h.deliverToUsers(m.UserIDs, m.Event)
+	}
+}
+
+// connections returns a snapshot of the open connections of the users.
+func (h *Hub) connections(userIDs ...string) []*User {
+	h.clientsMu.RLock()
+	defer h.clientsMu.RUnlock()
+
+	users := make([]*User, 0)
+	for _, id := range userIDs {
+		for u := range h.clients[id] {
+			users = append(users, u)
+		}
+	}
+	return users
+}
+
+// saveMessage stores a chat message sent by u so it gets an id and timestamp
+// before it's broadcast. Join and leave notices aren't stored.
+func (h *Hub) saveMessage(ctx context.Context, u *User, content string, parentID int64) (*Message, error) {
+	const op = "ws.Hub.saveMessage"
+
+	userID, err := strconv.ParseInt(u.ID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	m := &message.Message{
+		RoomID:  u.RoomID,
+		UserID:  userID,
+		Content: content,
+	}
+	if parentID != 0 {
+		m.ParentID = &parentID
+	}
+
+	m, err = h.messages.CreateMessage(ctx, m)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	m.Username = u.Username
+
+	return toMessage(m), nil
+}
+
+// History returns a page of stored messages of the room.
+func (h *Hub) History(ctx context.Context, roomID string, page message.Page) ([]*Message, bool, error) {
+	const op = "ws.Hub.History"
+
+	stored, hasMore, err := h.messages.ListRoomMessages(ctx, roomID, page)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	messages := make([]*Message, 0, len(stored))
+	for _, m := range stored {
+		messages = append(messages, toMessage(m))
+	}
+
+	return messages, hasMore, nil
+}
+
+// Publish broadcasts a message stored outside the hub, e.g. one posted over
+// REST, to the members of its room.
+func (h *Hub) Publish(m *message.Message) {
+	h.Broadcast(toMessage(m))
+}
+
+// PublishDirect delivers a stored direct message to the open connections of
+// its recipient and to the sender's other connections.
+func (h *Hub) PublishDirect(dm *message.DirectMessage) {
+	m := &Message{
+		ID:          dm.ID,
+		Content:     dm.Content,
+		UserID:      strconv.FormatInt(dm.SenderID, 10),
+		Username:    dm.SenderName,
+		RecipientID: strconv.FormatInt(dm.RecipientID, 10),
+		CreatedAt:   dm.CreatedAt,
+	}
+
+	e := newEnvelope(EventDirectMessage, "", m)
+	userIDs := []string{m.RecipientID, m.UserID}
+	h.deliverToUsers(userIDs, e)
+	h.publish(&BrokerMessage{UserIDs: userIDs, Event: e})
+}
+
+func toMessage(m *message.Message) *Message {
+	msg := &Message{
+		ID:         m.ID,
+		Content:    m.Content,
+		RoomID:     m.RoomID,
+		UserID:     strconv.FormatInt(m.UserID, 10),
+		Username:   m.Username,
+		ReplyCount: m.ReplyCount,
+		Reactions:  m.Reactions,
+		Mentions:   m.Mentions,
+		CreatedAt:  m.CreatedAt,
+		EditedAt:   m.EditedAt,
+		DeletedAt:  m.DeletedAt,
+	}
+	if m.ParentID != nil {
+		msg.ParentID = *m.ParentID
+	}
+	return msg
+}
+
+// replayHistory runs on the room goroutine right after registration, so
+// nothing broadcast afterwards can overtake what it sends, and messages that
+// were already part of it are skipped by broadcastToUserRoom. A resumed user
+// gets everything stored after the last message it has seen and a
+// session.resumed event; anyone else gets the last HistorySize messages.
+func (h *Hub) replayHistory(ctx context.Context, r *Room, u *User) {
+	ctx, cancel := context.WithTimeout(ctx, storageTimeout)
+	defer cancel()
+
+	if u.resumeFrom > 0 {
+		// query from where the client left off rather than from what CatchUp
+		// got to: a message with a lower id may have committed since
+		err := h.replayAfter(ctx, r.RoomId, u.resumeFrom, func(e *Envelope) error {
+			if u.markReplayed(e.seq) {
+				u.send(e)
+				u.replayed++
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("replayHistoryError: %v", err)
+		}
+		u.send(newEnvelope(EventResumed, "", ResumedPayload{
+			RoomID:   r.RoomId,
+			FromID:   u.resumeFrom,
+			LastID:   max(u.lastID, u.resumeFrom),
+			Replayed: u.replayed,
+		}))
+		return
+	}
+
+	if r.HistorySize <= 0 {
+		return
+	}
+
+	messages, _, err := h.History(ctx, r.RoomId, message.Page{Limit: r.HistorySize})
+	if err != nil {
+		log.Printf("replayHistoryError: %v", err)
+		return
+	}
+
+	for _, m := range messages {
+		m.History = true
+		u.send(messageEvent(m))
+		u.markReplayed(m.ID)
+	}
+}
+
+// replayAfter hands every stored message of the room newer than after to
+// deliver, oldest first, page by page. Replies are replayed as thread.reply
+// events with the current reply count of their parent.
+func (h *Hub) replayAfter(ctx context.Context, roomID string, after int64, deliver func(*Envelope) error) error {
+	const op = "ws.Hub.replayAfter"
+	replyCounts := make(map[int64]int)
+
+	for {
+		messages, hasMore, err := h.messages.ListRoomMessagesAfter(ctx, roomID, after, message.MaxLimit)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, stored := range messages {
+			m := toMessage(stored)
+			m.History = true
+
+			e := messageEvent(m)
+			if m.ParentID != 0 {
+				count, ok := replyCounts[m.ParentID]
+				if !ok {
+					count = h.replyCount(ctx, m.ParentID)
+					replyCounts[m.ParentID] = count
+				}
+				e = replyEvent(m, count)
+			}
+			if err := deliver(e); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			after = m.ID
+		}
+
+		if !hasMore || len(messages) == 0 {
+			return nil
+		}
+	}
+}
+
+// CatchUp hands every message of the room the user missed since lastSeenID
+// straight to deliver, before the writer starts and before the user is
+// registered. Whatever arrives in between is replayed on registration.
+func (h *Hub) CatchUp(ctx context.Context, u *User, lastSeenID int64, deliver func(*Envelope) error) error {
+	const op = "ws.Hub.CatchUp"
+
+	u.resumeFrom = lastSeenID
+
+	err := h.replayAfter(ctx, u.RoomID, lastSeenID, func(e *Envelope) error {
+		if err := deliver(e); err != nil {
+			return err
+		}
+		u.markReplayed(e.seq)
+		u.replayed++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
 }
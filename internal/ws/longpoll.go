@@ -0,0 +1,195 @@
+package ws
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// pollWait is how long a poll waits for the first event.
+	pollWait = 25 * time.Second
+	// pollSessionTTL is how long a session stays registered between polls.
+	pollSessionTTL = 60 * time.Second
+	maxPollEvents  = 100
+)
+
+// PollRes is the answer to a long poll. Closed means the server ended the
+// session, the client has to start a new one.
+type PollRes struct {
+	Session string      `json:"session"`
+	Events  []*Envelope `json:"events"`
+	Closed  bool        `json:"closed"`
+}
+
+// pollSession keeps a long-polling client registered in its room between
+// polls, its queue buffers whatever arrives in the meantime.
+type pollSession struct {
+	id    string
+	owner int64
+	user  *User
+	// busy is held for the duration of a poll.
+	busy  sync.Mutex
+	timer *time.Timer
+}
+
+type pollSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*pollSession
+}
+
+func newPollSessions() *pollSessions {
+	return &pollSessions{sessions: make(map[string]*pollSession)}
+}
+
+func (p *pollSessions) get(id string) (*pollSession, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.sessions[id]
+	return s, ok
+}
+
+// add registers a session that expires after pollSessionTTL without polls.
+func (p *pollSessions) add(h *Hub, s *pollSession) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.sessions[s.id] = s
+	s.timer = time.AfterFunc(pollSessionTTL, func() {
+		p.remove(h, s)
+	})
+}
+
+func (p *pollSessions) remove(h *Hub, s *pollSession) {
+	p.mu.Lock()
+	_, ok := p.sessions[s.id]
+	delete(p.sessions, s.id)
+	p.mu.Unlock()
+
+	if ok {
+		s.timer.Stop()
+		h.Unregister(s.user)
+	}
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Poll godoc
+// @Summary      Long-poll room events
+// @Description  Long-polling fallback for clients that can't use WebSockets or server-sent events. The first poll (without session) joins the room and returns a session id to pass on the following polls. A poll answers as soon as events are available, or after 25 seconds with an empty list. Events are the same envelopes as in the WebSocket protocol. A session expires 60 seconds after the last poll.
+// @Tags         room
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id          path      string  true   "Room ID"
+// @Param        session     query     string  false  "Session id returned by the previous poll"
+// @Param        lastSeenId  query     int     false  "Id of the last message received, to resume from when starting a session"
+// @Param        invite      query     string  false  "Invite code, accepted when the caller isn't a member yet"
+// @Success      200         {object}  PollRes
+// @Failure      400         {object}  ErrorResponse  "Bad request"
+// @Failure      401         {object}  ErrorResponse  "Unauthorized"
+// @Failure      403         {object}  ErrorResponse  "Not a member of the room"
+// @Failure      404         {object}  ErrorResponse  "Room or session not found"
+// @Failure      409         {object}  ErrorResponse  "Another poll is in progress"
+// @Router       /rooms/{id}/poll [get]
+func (h *Handler) Poll(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+
+	req, ok := h.prepareJoin(w, r, roomID, r.URL.Query().Get("lastSeenId"))
+	if !ok {
+		return
+	}
+
+	res := &PollRes{Events: make([]*Envelope, 0)}
+
+	var s *pollSession
+	if id := r.URL.Query().Get("session"); id != "" {
+		s, ok = h.polls.get(id)
+		if !ok || s.owner != req.principal.ID || s.user.RoomID != roomID {
+			h.sendErrorResponse(w, "Session not found", http.StatusNotFound)
+			return
+		}
+	} else {
+		user := req.newUser(h.hub.cfg, nil)
+		if req.lastSeenID > 0 {
+			catchUp := func(e *Envelope) error {
+				res.Events = append(res.Events, e)
+				return nil
+			}
+			if err := h.hub.CatchUp(r.Context(), user, req.lastSeenID, catchUp); err != nil {
+				h.Log.Error("Failed to catch up", "user_id", user.ID, "room_id", roomID, "error", err)
+				h.sendErrorResponse(w, "Couldn't replay missed messages", http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := h.hub.Join(r.Context(), user); err != nil {
+			h.Log.Error("Failed to register user", "user_id", user.ID, "room_id", roomID, "error", err)
+			h.sendErrorResponse(w, "Couldn't join the room", http.StatusInternalServerError)
+			return
+		}
+
+		s = &pollSession{id: newSessionID(), owner: req.principal.ID, user: user}
+		h.polls.add(h.hub, s)
+		h.Log.Info("Long-polling session started", "user_id", user.ID, "room_id", roomID, "session", s.id)
+	}
+	res.Session = s.id
+
+	if !s.busy.TryLock() {
+		h.sendErrorResponse(w, "Another poll is in progress", http.StatusConflict)
+		return
+	}
+	s.timer.Stop()
+	res.Closed = h.collect(r, s.user, res)
+	s.busy.Unlock()
+
+	if res.Closed {
+		h.polls.remove(h.hub, s)
+	} else {
+		s.timer.Reset(pollSessionTTL)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// collect waits for events queued for the user and appends them to res. It
+// reports whether the queue was closed.
+func (h *Handler) collect(r *http.Request, u *User, res *PollRes) bool {
+	if len(res.Events) == 0 {
+		timer := time.NewTimer(pollWait)
+		defer timer.Stop()
+
+		select {
+		case e, ok := <-u.Message:
+			if !ok {
+				return true
+			}
+			res.Events = append(res.Events, e)
+		case <-timer.C:
+			return false
+		case <-r.Context().Done():
+			return false
+		}
+	}
+
+	for len(res.Events) < maxPollEvents {
+		select {
+		case e, ok := <-u.Message:
+			if !ok {
+				return true
+			}
+			res.Events = append(res.Events, e)
+		default:
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,535 @@
+package ws
+
+import (
+	"HomeWork5/internal/middleware"
+	"HomeWork5/internal/room"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ModerationReq comes with moderation actions. Duration is the length of a
+// ban or mute in seconds, 0 for one that doesn't end; Role is the new role
+// when changing roles.
+type ModerationReq struct {
+	Reason   string `json:"reason"`
+	Duration int    `json:"duration"`
+	Role     string `json:"role"`
+}
+
+type RestrictionsRes struct {
+	Restrictions []*room.Restriction `json:"restrictions"`
+	HasMore      bool                `json:"hasMore"`
+}
+
+type ModerationRes struct {
+	Entries []*room.ModerationEntry `json:"entries"`
+	HasMore bool                    `json:"hasMore"`
+}
+
+// Role returns the role of the user in the room. The owner of the room and
+// admins rank as owners, users that aren't members have no role.
+func (h *Hub) Role(ctx context.Context, rm *Room, userID int64, admin bool) (room.Role, error) {
+	const op = "ws.Hub.Role"
+
+	if admin || (rm.CreatedBy != 0 && rm.CreatedBy == userID) {
+		return room.RoleOwner, nil
+	}
+
+	role, err := h.rooms.MemberRole(ctx, rm.RoomId, userID)
+	if errors.Is(err, room.ErrMemberNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return role, nil
+}
+
+// Banned returns the ban keeping the user out of the room, if any.
+func (h *Hub) Banned(ctx context.Context, roomID string, userID int64) (*room.Restriction, bool, error) {
+	const op = "ws.Hub.Banned"
+
+	ban, err := h.rooms.ActiveRestriction(ctx, roomID, userID, room.RestrictionBan)
+	if errors.Is(err, room.ErrRestrictionNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ban, true, nil
+}
+
+// Moderate applies a moderation action, records it and lets the room know on
+// every instance. Kicked and banned users are disconnected after the event.
+func (h *Hub) Moderate(ctx context.Context, e *room.ModerationEntry) (*room.ModerationEntry, error) {
+	const op = "ws.Hub.Moderate"
+
+	entry, err := h.rooms.Moderate(ctx, e)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ev := newEnvelope(EventModeration, "", entry)
+	h.deliverToRoom(entry.RoomID, ev)
+	h.applyModeration(entry)
+	h.publish(&BrokerMessage{RoomID: entry.RoomID, Event: ev})
+
+	return entry, nil
+}
+
+// applyModeration enforces an action on the local connections of the room.
+func (h *Hub) applyModeration(e *room.ModerationEntry) {
+	r, ok := h.lookup(e.RoomID)
+	if !ok {
+		return
+	}
+	userID := strconv.FormatInt(e.TargetID, 10)
+
+	switch e.Action {
+	case room.ActionMute:
+		r.setMuted(userID, e.ExpiresAt, true)
+	case room.ActionUnmute:
+		r.setMuted(userID, nil, false)
+	case room.ActionKick, room.ActionBan:
+		reason := "kicked from the room"
+		if e.Action == room.ActionBan {
+			reason = "banned from the room"
+		}
+		select {
+		case r.kick <- kick{userID: userID, reason: reason}:
+		case <-r.done:
+		}
+	}
+}
+
+// muted reports whether the user can't send to the room and until when.
+func (h *Hub) muted(roomID, userID string) (*time.Time, bool) {
+	r, ok := h.lookup(roomID)
+	if !ok {
+		return nil, false
+	}
+	return r.mutedUntil(userID)
+}
+
+// checkBan rejects banned users, answering the request.
+func (h *Handler) checkBan(w http.ResponseWriter, r *http.Request, rm *Room, p *middleware.Principal) bool {
+	if p.Admin || rm.CreatedBy == p.ID {
+		return true
+	}
+
+	ban, banned, err := h.hub.Banned(r.Context(), rm.RoomId, p.ID)
+	if err != nil {
+		h.Log.Error("Failed to check bans", "room_id", rm.RoomId, "error", err)
+		h.sendErrorResponse(w, "Couldn't check room access", http.StatusInternalServerError)
+		return false
+	}
+	if !banned {
+		return true
+	}
+
+	if ban.ExpiresAt != nil {
+		h.sendErrorResponse(w, "You are banned from this room until "+ban.ExpiresAt.UTC().Format(time.RFC3339), http.StatusForbidden)
+		return false
+	}
+	h.sendErrorResponse(w, "You are banned from this room", http.StatusForbidden)
+	return false
+}
+
+// authorizeModeration makes sure the caller may moderate the target user in
+// the room: moderators act on members, owners and admins on everyone else but
+// the owner, whom bans, mutes and roles never apply to. It returns the room
+// along with the caller.
+func (h *Handler) authorizeModeration(w http.ResponseWriter, r *http.Request, roomID string, targetID int64) (*Room, *middleware.Principal, bool) {
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		h.sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+	if p.ID == targetID {
+		h.sendErrorResponse(w, "You can't moderate yourself", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	rm, ok := h.loadRoom(w, r, roomID)
+	if !ok {
+		return nil, nil, false
+	}
+	if rm.CreatedBy != 0 && rm.CreatedBy == targetID {
+		h.sendErrorResponse(w, "The room owner can't be moderated", http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	actor, err := h.hub.Role(r.Context(), rm, p.ID, p.Admin)
+	if err != nil {
+		h.Log.Error("Failed to load role", "room_id", roomID, "error", err)
+		h.sendErrorResponse(w, "Couldn't check permissions", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if actor.Rank() < room.RoleModerator.Rank() {
+		h.sendErrorResponse(w, "Only moderators can do that", http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	target, err := h.hub.Role(r.Context(), rm, targetID, false)
+	if err != nil {
+		h.Log.Error("Failed to load role", "room_id", roomID, "error", err)
+		h.sendErrorResponse(w, "Couldn't check permissions", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if !p.Admin && target.Rank() >= actor.Rank() {
+		h.sendErrorResponse(w, "You can't moderate a user with the same or a higher role", http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	return rm, p, true
+}
+
+// decodeModerationReq reads the optional request body.
+func decodeModerationReq(r *http.Request) (*ModerationReq, error) {
+	var req ModerationReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if req.Duration < 0 {
+		return nil, errors.New("duration can't be negative")
+	}
+	return &req, nil
+}
+
+// moderate runs a moderation action on the user in the path and answers with
+// the audit entry.
+func (h *Handler) moderate(w http.ResponseWriter, r *http.Request, action room.Action) {
+	roomID := chi.URLParam(r, "id")
+
+	targetID, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	if err != nil || targetID <= 0 {
+		h.sendErrorResponse(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	req, err := decodeModerationReq(r)
+	if err != nil {
+		h.sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	entry := &room.ModerationEntry{
+		RoomID:   roomID,
+		Action:   action,
+		TargetID: targetID,
+		Reason:   req.Reason,
+	}
+
+	if action == room.ActionRole {
+		entry.Role = room.Role(req.Role)
+		if entry.Role != room.RoleModerator && entry.Role != room.RoleMember {
+			h.sendErrorResponse(w, "Role must be moderator or member", http.StatusBadRequest)
+			return
+		}
+	}
+	if (action == room.ActionBan || action == room.ActionMute) && req.Duration > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.Duration) * time.Second)
+		entry.ExpiresAt = &expiresAt
+	}
+
+	rm, p, ok := h.authorizeModeration(w, r, roomID, targetID)
+	if !ok {
+		return
+	}
+	entry.ActorID = p.ID
+
+	if action == room.ActionRole {
+		// only the owner appoints moderators
+		if !p.Admin && rm.CreatedBy != p.ID {
+			h.sendErrorResponse(w, "Only the room owner or an admin can change roles", http.StatusForbidden)
+			return
+		}
+		if _, err := h.hub.rooms.MemberRole(r.Context(), roomID, targetID); err != nil {
+			if errors.Is(err, room.ErrMemberNotFound) {
+				h.sendErrorResponse(w, "Not a member of this room", http.StatusNotFound)
+				return
+			}
+			h.Log.Error("Failed to load role", "room_id", roomID, "error", err)
+			h.sendErrorResponse(w, "Couldn't change the role", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	entry, err = h.hub.Moderate(r.Context(), entry)
+	switch {
+	case errors.Is(err, room.ErrRoomNotFound):
+		h.sendErrorResponse(w, "Room not found", http.StatusNotFound)
+		return
+	case errors.Is(err, room.ErrUserNotFound):
+		h.sendErrorResponse(w, "User not found", http.StatusNotFound)
+		return
+	case err != nil:
+		h.Log.Error("Failed to moderate", "room_id", roomID, "action", action, "error", err)
+		h.sendErrorResponse(w, "Couldn't apply the action", http.StatusInternalServerError)
+		return
+	}
+
+	h.Log.Info("Moderation action applied", "room_id", roomID, "action", action, "actor_id", p.ID, "target_id", targetID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entry)
+}
+
+// Kick godoc
+// @Summary      Kick a user
+// @Description  Disconnects every connection of the user from the room with close code 1008. The user may join again unless banned. Members get a "moderation" event. Moderators may kick members, the owner and admins anyone.
+// @Tags         moderation
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      string         true   "Room ID"
+// @Param        userId  path      int            true   "User ID"
+// @Param        req     body      ModerationReq  false  "Reason"
+// @Success      200     {object}  room.ModerationEntry
+// @Failure      400     {object}  ErrorResponse  "Bad request"
+// @Failure      401     {object}  ErrorResponse  "Unauthorized"
+// @Failure      403     {object}  ErrorResponse  "Forbidden"
+// @Failure      404     {object}  ErrorResponse  "Room or user not found"
+// @Failure      500     {object}  ErrorResponse  "Internal error"
+// @Router       /rooms/{id}/members/{userId}/kick [post]
+func (h *Handler) Kick(w http.ResponseWriter, r *http.Request) {
+	h.moderate(w, r, room.ActionKick)
+}
+
+// Ban godoc
+// @Summary      Ban a user
+// @Description  Keeps the user out of the room for duration seconds, or for good without one, and disconnects them. Banning again replaces the ban. Members get a "moderation" event.
+// @Tags         moderation
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      string         true   "Room ID"
+// @Param        userId  path      int            true   "User ID"
+// @Param        req     body      ModerationReq  false  "Reason and duration"
+// @Success      200     {object}  room.ModerationEntry
+// @Failure      400     {object}  ErrorResponse  "Bad request"
+// @Failure      401     {object}  ErrorResponse  "Unauthorized"
+// @Failure      403     {object}  ErrorResponse  "Forbidden"
+// @Failure      404     {object}  ErrorResponse  "Room or user not found"
+// @Failure      500     {object}  ErrorResponse  "Internal error"
+// @Router       /rooms/{id}/bans/{userId} [put]
+func (h *Handler) Ban(w http.ResponseWriter, r *http.Request) {
+	h.moderate(w, r, room.ActionBan)
+}
+
+// Unban godoc
+// @Summary      Lift a ban
+// @Tags         moderation
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      string  true  "Room ID"
+// @Param        userId  path      int     true  "User ID"
+// @Success      200     {object}  room.ModerationEntry
+// @Failure      400     {object}  ErrorResponse  "Bad request"
+// @Failure      401     {object}  ErrorResponse  "Unauthorized"
+// @Failure      403     {object}  ErrorResponse  "Forbidden"
+// @Failure      404     {object}  ErrorResponse  "Room or user not found"
+// @Failure      500     {object}  ErrorResponse  "Internal error"
+// @Router       /rooms/{id}/bans/{userId} [delete]
+func (h *Handler) Unban(w http.ResponseWriter, r *http.Request) {
+	h.moderate(w, r, room.ActionUnban)
+}
+
+// Mute godoc
+// @Summary      Mute a user
+// @Description  Makes the room reject "message.send" events of the user with a "muted" error event for duration seconds, or for good without one. Members get a "moderation" event.
+// @Tags         moderation
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      string         true   "Room ID"
+// @Param        userId  path      int            true   "User ID"
+// @Param        req     body      ModerationReq  false  "Reason and duration"
+// @Success      200     {object}  room.ModerationEntry
+// @Failure      400     {object}  ErrorResponse  "Bad request"
+// @Failure      401     {object}  ErrorResponse  "Unauthorized"
+// @Failure      403     {object}  ErrorResponse  "Forbidden"
+// @Failure      404     {object}  ErrorResponse  "Room or user not found"
+// @Failure      500     {object}  ErrorResponse  "Internal error"
+// @Router       /rooms/{id}/mutes/{userId} [put]
+func (h *Handler) Mute(w http.ResponseWriter, r *http.Request) {
+	h.moderate(w, r, room.ActionMute)
+}
+
+// Unmute godoc
+// @Summary      Lift a mute
+// @Tags         moderation
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      string  true  "Room ID"
+// @Param        userId  path      int     true  "User ID"
+// @Success      200     {object}  room.ModerationEntry
+// @Failure      400     {object}  ErrorResponse  "Bad request"
+// @Failure      401     {object}  ErrorResponse  "Unauthorized"
+// @Failure      403     {object}  ErrorResponse  "Forbidden"
+// @Failure      404     {object}  ErrorResponse  "Room or user not found"
+// @Failure      500     {object}  ErrorResponse  "Internal error"
+// @Router       /rooms/{id}/mutes/{userId} [delete]
+func (h *Handler) Unmute(w http.ResponseWriter, r *http.Request) {
+	h.moderate(w, r, room.ActionUnmute)
+}
+
+// SetRole godoc
+// @Summary      Change the role of a member
+// @Description  Appoints a member as moderator or makes a moderator a member again. Only the owner of the room or an admin may change roles.
+// @Tags         moderation
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      string         true  "Room ID"
+// @Param        userId  path      int            true  "User ID"
+// @Param        req     body      ModerationReq  true  "New role"
+// @Success      200     {object}  room.ModerationEntry
+// @Failure      400     {object}  ErrorResponse  "Bad request"
+// @Failure      401     {object}  ErrorResponse  "Unauthorized"
+// @Failure      403     {object}  ErrorResponse  "Forbidden"
+// @Failure      404     {object}  ErrorResponse  "Room or member not found"
+// @Failure      500     {object}  ErrorResponse  "Internal error"
+// @Router       /rooms/{id}/members/{userId}/role [put]
+func (h *Handler) SetRole(w http.ResponseWriter, r *http.Request) {
+	h.moderate(w, r, room.ActionRole)
+}
+
+// ListBans godoc
+// @Summary      List bans
+// @Description  Returns a page of the active bans of the room. Only moderators may see them.
+// @Tags         moderation
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      string  true   "Room ID"
+// @Param        limit   query     int     false  "Page size, 50 by default and 100 at most"
+// @Param        offset  query     int     false  "Number of bans to skip"
+// @Success      200     {object}  RestrictionsRes
+// @Failure      400     {object}  ErrorResponse  "Bad request"
+// @Failure      401     {object}  ErrorResponse  "Unauthorized"
+// @Failure      403     {object}  ErrorResponse  "Forbidden"
+// @Failure      404     {object}  ErrorResponse  "Room not found"
+// @Failure      500     {object}  ErrorResponse  "Internal error"
+// @Router       /rooms/{id}/bans [get]
+func (h *Handler) ListBans(w http.ResponseWriter, r *http.Request) {
+	h.listRestrictions(w, r, room.RestrictionBan)
+}
+
+// ListMutes godoc
+// @Summary      List mutes
+// @Description  Returns a page of the active mutes of the room. Only moderators may see them.
+// @Tags         moderation
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      string  true   "Room ID"
+// @Param        limit   query     int     false  "Page size, 50 by default and 100 at most"
+// @Param        offset  query     int     false  "Number of mutes to skip"
+// @Success      200     {object}  RestrictionsRes
+// @Failure      400     {object}  ErrorResponse  "Bad request"
+// @Failure      401     {object}  ErrorResponse  "Unauthorized"
+// @Failure      403     {object}  ErrorResponse  "Forbidden"
+// @Failure      404     {object}  ErrorResponse  "Room not found"
+// @Failure      500     {object}  ErrorResponse  "Internal error"
+// @Router       /rooms/{id}/mutes [get]
+func (h *Handler) ListMutes(w http.ResponseWriter, r *http.Request) {
+	h.listRestrictions(w, r, room.RestrictionMute)
+}
+
+func (h *Handler) listRestrictions(w http.ResponseWriter, r *http.Request, kind room.RestrictionKind) {
+	roomID := chi.URLParam(r, "id")
+
+	q, err := parseListQuery(r)
+	if err != nil {
+		h.sendErrorResponse(w, "Invalid pagination parameters", http.StatusBadRequest)
+		return
+	}
+
+	if !h.authorizeModerator(w, r, roomID) {
+		return
+	}
+
+	restrictions, hasMore, err := h.hub.rooms.ListRestrictions(r.Context(), roomID, kind, q)
+	if err != nil {
+		h.Log.Error("Failed to list restrictions", "room_id", roomID, "kind", kind, "error", err)
+		h.sendErrorResponse(w, "Couldn't list restrictions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RestrictionsRes{Restrictions: restrictions, HasMore: hasMore})
+}
+
+// GetModerationLog godoc
+// @Summary      Moderation audit trail
+// @Description  Returns a page of the moderation actions taken in the room, newest first. Only moderators may see it.
+// @Tags         moderation
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      string  true   "Room ID"
+// @Param        limit   query     int     false  "Page size, 50 by default and 100 at most"
+// @Param        offset  query     int     false  "Number of entries to skip"
+// @Success      200     {object}  ModerationRes
+// @Failure      400     {object}  ErrorResponse  "Bad request"
+// @Failure      401     {object}  ErrorResponse  "Unauthorized"
+// @Failure      403     {object}  ErrorResponse  "Forbidden"
+// @Failure      404     {object}  ErrorResponse  "Room not found"
+// @Failure      500     {object}  ErrorResponse  "Internal error"
+// @Router       /rooms/{id}/moderation [get]
+func (h *Handler) GetModerationLog(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+
+	q, err := parseListQuery(r)
+	if err != nil {
+		h.sendErrorResponse(w, "Invalid pagination parameters", http.StatusBadRequest)
+		return
+	}
+
+	if !h.authorizeModerator(w, r, roomID) {
+		return
+	}
+
+	entries, hasMore, err := h.hub.rooms.ListModeration(r.Context(), roomID, q)
+	if err != nil {
+		h.Log.Error("Failed to list moderation log", "room_id", roomID, "error", err)
+		h.sendErrorResponse(w, "Couldn't list the moderation log", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ModerationRes{Entries: entries, HasMore: hasMore})
+}
+
+// authorizeModerator makes sure the caller moderates the room, answering the
+// request otherwise.
+func (h *Handler) authorizeModerator(w http.ResponseWriter, r *http.Request, roomID string) bool {
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		h.sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return false
+	}
+
+	rm, ok := h.loadRoom(w, r, roomID)
+	if !ok {
+		return false
+	}
+
+	role, err := h.hub.Role(r.Context(), rm, p.ID, p.Admin)
+	if err != nil {
+		h.Log.Error("Failed to load role", "room_id", roomID, "error", err)
+		h.sendErrorResponse(w, "Couldn't check permissions", http.StatusInternalServerError)
+		return false
+	}
+	if role.Rank() < room.RoleModerator.Rank() {
+		h.sendErrorResponse(w, "Only moderators can do that", http.StatusForbidden)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,162 @@
+package ws
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"log"
+	"time"
+)
+
+const (
+	pgBrokerChannel = "ws_events"
+	// NOTIFY payloads are limited to 8000 bytes; bigger ones are spilled into
+	// the ws_broker_payloads table and only their id is sent.
+	maxNotifyPayload = 7900
+	spillRetention   = "1 minute"
+	pgListenerPing   = 90 * time.Second
+	// the listener reconnects with a delay doubling between these bounds,
+	// failed LISTENs are retried the same way
+	pgListenerMinDelay = 100 * time.Millisecond
+	pgListenerMaxDelay = time.Minute
+)
+
+// PostgresBroker fans events out to every server instance through
+// PostgreSQL LISTEN/NOTIFY.
+type PostgresBroker struct {
+	db  *sql.DB
+	dsn string
+}
+
+func NewPostgresBroker(db *sql.DB, dsn string) *PostgresBroker {
+	return &PostgresBroker{db: db, dsn: dsn}
+}
+
+type notifyPayload struct {
+	Spill int64 `json:"spill,omitempty"`
+}
+
+func (b *PostgresBroker) Publish(ctx context.Context, m *BrokerMessage) error {
+	const op = "ws.PostgresBroker.Publish"
+
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(payload) > maxNotifyPayload {
+		var id int64
+		query := "INSERT INTO ws_broker_payloads (payload) VALUES ($1) RETURNING id"
+		if err := b.db.QueryRowContext(ctx, query, payload).Scan(&id); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		query = "DELETE FROM ws_broker_payloads WHERE created_at < now() - interval '" + spillRetention + "'"
+		if _, err := b.db.ExecContext(ctx, query); err != nil {
+			log.Printf("brokerCleanupError: %v", err)
+		}
+
+		payload, _ = json.Marshal(notifyPayload{Spill: id})
+	}
+
+	if _, err := b.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", pgBrokerChannel, string(payload)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (b *PostgresBroker) Subscribe(ctx context.Context, deliver func(*BrokerMessage)) error {
+	listener := pq.NewListener(b.dsn, pgListenerMinDelay, pgListenerMaxDelay, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("brokerListenerError: %v", err)
+		}
+	})
+	defer listener.Close()
+
+	if !listen(ctx, listener) {
+		return nil
+	}
+
+	ping := time.NewTicker(pgListenerPing)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			if n == nil {
+				// the connection was re-established, anything sent meanwhile
+				// is lost; clients recover through lastSeenId
+				log.Printf("brokerListener: reconnected, events may have been missed")
+				continue
+			}
+
+			m, err := b.decode(ctx, n.Extra)
+			if err != nil {
+				log.Printf("brokerDecodeError: %v", err)
+				continue
+			}
+			deliver(m)
+		case <-ping.C:
+			go listener.Ping()
+		}
+	}
+}
+
+// listen starts listening on the broker channel, retrying failed attempts
+// until it succeeds or ctx is done, which it reports with false. Listen
+// itself blocks until the listener first connects, so it's waited for
+// aside; closing the listener releases it.
+func listen(ctx context.Context, listener *pq.Listener) bool {
+	delay := pgListenerMinDelay
+	for {
+		done := make(chan error, 1)
+		go func() { done <- listener.Listen(pgBrokerChannel) }()
+
+		var err error
+		select {
+		case <-ctx.Done():
+			return false
+		case err = <-done:
+		}
+		if err == nil || errors.Is(err, pq.ErrChannelAlreadyOpen) {
+			return true
+		}
+
+		log.Printf("brokerListenError: %v, retrying in %s", err, delay)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, pgListenerMaxDelay)
+	}
+}
+
+func (b *PostgresBroker) decode(ctx context.Context, extra string) (*BrokerMessage, error) {
+	const op = "ws.PostgresBroker.decode"
+	data := []byte(extra)
+
+	var p notifyPayload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if p.Spill != 0 {
+		query := "SELECT payload FROM ws_broker_payloads WHERE id = $1"
+		if err := b.db.QueryRowContext(ctx, query, p.Spill).Scan(&data); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	m := &BrokerMessage{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,366 @@
+package ws
+
+import (
+	"HomeWork5/internal/middleware"
+	"HomeWork5/internal/presence"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// PresenceUpdate is published to the other instances when the presence of a
+// user changes on this one. Connected tells whether the user has connections
+// here.
+type PresenceUpdate struct {
+	UserID    string             `json:"userId"`
+	Connected bool               `json:"connected"`
+	Presence  *presence.Presence `json:"presence"`
+}
+
+// online reports whether the user has a connection to this or, as far as the
+// updates published by the others tell, any other instance. Every instance
+// republishes its connected users each PresenceInterval; users it stops
+// reporting, e.g. because it died, go offline after two intervals.
+func (h *Hub) online(userID string) bool {
+	if len(h.connections(userID)) > 0 {
+		return true
+	}
+
+	h.presenceMu.RLock()
+	defer h.presenceMu.RUnlock()
+
+	for _, seen := range h.remote[userID] {
+		if time.Since(seen) < h.presenceTTL() {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Hub) presenceTTL() time.Duration {
+	return 2 * h.cfg.PresenceInterval
+}
+
+// runPresence republishes the users connected here every PresenceInterval and
+// expires the ones the other instances stopped reporting.
+func (h *Hub) runPresence() {
+	defer h.wg.Done()
+
+	ticker := time.NewTicker(h.cfg.PresenceInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ticker.C:
+			h.clientsMu.RLock()
+			online := make([]string, 0, len(h.clients))
+			for id := range h.clients {
+				online = append(online, id)
+			}
+			h.clientsMu.RUnlock()
+
+			if len(online) > 0 {
+				h.publish(&BrokerMessage{Online: online})
+			}
+			h.expirePresence()
+		}
+	}
+}
+
+// refreshPresence takes in the users another instance reports as connected.
+func (h *Hub) refreshPresence(node string, userIDs []string) {
+	h.presenceMu.Lock()
+	defer h.presenceMu.Unlock()
+
+	now := time.Now()
+	for _, id := range userIDs {
+		nodes, ok := h.remote[id]
+		if !ok {
+			nodes = make(map[string]time.Time)
+			h.remote[id] = nodes
+		}
+		nodes[node] = now
+	}
+}
+
+// expirePresence forgets the remote connections that weren't reported for
+// two intervals and tells the local contacts of users left with none that
+// they went offline.
+func (h *Hub) expirePresence() {
+	var offline []string
+
+	h.presenceMu.Lock()
+	for id, nodes := range h.remote {
+		for node, seen := range nodes {
+			if time.Since(seen) >= h.presenceTTL() {
+				delete(nodes, node)
+			}
+		}
+		if len(nodes) == 0 {
+			delete(h.remote, id)
+			offline = append(offline, id)
+		}
+	}
+	h.presenceMu.Unlock()
+
+	for _, userID := range offline {
+		if len(h.connections(userID)) > 0 {
+			continue
+		}
+		if err := h.pushOffline(userID); err != nil {
+			log.Printf("presenceError: %v", err)
+		}
+	}
+}
+
+// pushOffline sends the presence of a user who went offline without an
+// announce to their contacts connected here.
+func (h *Hub) pushOffline(userID string) error {
+	const op = "ws.Hub.pushOffline"
+
+	id, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	ctx, cancel := context.WithTimeout(h.ctx, storageTimeout)
+	defer cancel()
+
+	stored, err := h.presence.GetPresence(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	contacts, err := h.presence.Contacts(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	userIDs := make([]string, 0, len(contacts))
+	for _, c := range contacts {
+		userIDs = append(userIDs, strconv.FormatInt(c, 10))
+	}
+	h.deliverToUsers(userIDs, newEnvelope(EventPresence, "", h.effective(stored)))
+
+	return nil
+}
+
+// effective turns the stored presence of a user into what others see.
+func (h *Hub) effective(p *presence.Presence) *presence.Presence {
+	shown := *p
+	if !h.online(strconv.FormatInt(p.UserID, 10)) {
+		shown.Status = presence.StatusOffline
+	}
+	return &shown
+}
+
+// Presence returns how the user currently shows to others.
+func (h *Hub) Presence(ctx context.Context, userID int64) (*presence.Presence, error) {
+	const op = "ws.Hub.Presence"
+
+	p, err := h.presence.GetPresence(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return h.effective(p), nil
+}
+
+// SetStatus stores the status set by the user and lets their contacts know.
+func (h *Hub) SetStatus(ctx context.Context, userID int64, status presence.Status, text string) (*presence.Presence, error) {
+	const op = "ws.Hub.SetStatus"
+
+	if !status.Valid() {
+		return nil, fmt.Errorf("%s: %w", op, presence.ErrInvalidStatus)
+	}
+	if utf8.RuneCountInString(text) > presence.MaxTextLength {
+		return nil, fmt.Errorf("%s: %w", op, presence.ErrTextTooLong)
+	}
+
+	if _, err := h.presence.SetStatus(ctx, userID, status, text); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	p, err := h.announce(ctx, strconv.FormatInt(userID, 10))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return p, nil
+}
+
+// connectionsChanged announces a user whose first connection to this instance
+// opened or whose last one closed.
+func (h *Hub) connectionsChanged(userID string) {
+	if h.ctx.Err() != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(h.ctx, storageTimeout)
+	defer cancel()
+
+	if _, err := h.announce(ctx, userID); err != nil {
+		log.Printf("presenceError: %v", err)
+	}
+}
+
+// presenceLock serializes the announces of a user, so that the last one
+// published reflects their latest connections. Users share the stripes.
+func (h *Hub) presenceLock(userID string) *sync.Mutex {
+	f := fnv.New32a()
+	f.Write([]byte(userID))
+	return &h.presenceLocks[f.Sum32()%shardCount]
+}
+
+// announce records that the user was seen and sends their presence to them
+// and their contacts on every instance. Contacts are resolved once here, the
+// other instances only deliver to them.
+func (h *Hub) announce(ctx context.Context, userID string) (*presence.Presence, error) {
+	const op = "ws.Hub.announce"
+
+	id, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	mu := h.presenceLock(userID)
+	mu.Lock()
+	defer mu.Unlock()
+
+	stored, err := h.presence.Touch(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	userIDs := []string{userID}
+	contacts, err := h.presence.Contacts(ctx, id)
+	if err != nil {
+		log.Printf("presenceError: %v", err)
+	}
+	for _, c := range contacts {
+		userIDs = append(userIDs, strconv.FormatInt(c, 10))
+	}
+
+	h.publish(&BrokerMessage{UserIDs: userIDs, Presence: &PresenceUpdate{
+		UserID:    userID,
+		Connected: len(h.connections(userID)) > 0,
+		Presence:  stored,
+	}})
+
+	p := h.effective(stored)
+	h.deliverToUsers(userIDs, newEnvelope(EventPresence, "", p))
+
+	return p, nil
+}
+
+// applyPresence takes in an update published by another instance and
+// delivers it to the users it was resolved for there.
+func (h *Hub) applyPresence(node string, upd *PresenceUpdate, userIDs []string) {
+	h.presenceMu.Lock()
+	nodes, ok := h.remote[upd.UserID]
+	if upd.Connected {
+		if !ok {
+			nodes = make(map[string]time.Time)
+			h.remote[upd.UserID] = nodes
+		}
+		nodes[node] = time.Now()
+	} else if ok {
+		delete(nodes, node)
+		if len(nodes) == 0 {
+			delete(h.remote, upd.UserID)
+		}
+	}
+	h.presenceMu.Unlock()
+
+	h.deliverToUsers(userIDs, newEnvelope(EventPresence, "", h.effective(upd.Presence)))
+}
+
+// GetPresence godoc
+// @Summary      Get the presence of a user
+// @Description  Returns the status of the user: "offline" while they have no connection, otherwise the status they set ("online", "away" or "dnd") with its text, along with when they were last seen. Changes are pushed as "presence" events to the users sharing a room or a conversation with them.
+// @Tags         user
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  presence.Presence
+// @Failure      400  {object}  ErrorResponse  "Bad request"
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      404  {object}  ErrorResponse  "User not found"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /users/{id}/presence [get]
+func (h *Handler) GetPresence(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || userID <= 0 {
+		h.sendErrorResponse(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	p, err := h.hub.Presence(r.Context(), userID)
+	if errors.Is(err, presence.ErrUserNotFound) {
+		h.sendErrorResponse(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Log.Error("Failed to load presence", "user_id", userID, "error", err)
+		h.sendErrorResponse(w, "Couldn't load the presence", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+// SetStatus godoc
+// @Summary      Set my status
+// @Description  Sets the status shown to others while the caller is connected: "online", "away" or "dnd", with an optional text of up to 100 characters. Users sharing a room or a conversation with the caller get a "presence" event.
+// @Tags         user
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        status  body      presence.StatusReq  true  "Status"
+// @Success      200     {object}  presence.Presence
+// @Failure      400     {object}  ErrorResponse  "Bad request"
+// @Failure      401     {object}  ErrorResponse  "Unauthorized"
+// @Failure      500     {object}  ErrorResponse  "Internal error"
+// @Router       /users/me/status [put]
+func (h *Handler) SetStatus(w http.ResponseWriter, r *http.Request) {
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		h.sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req presence.StatusReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.hub.SetStatus(r.Context(), p.ID, presence.Status(req.Status), req.Text)
+	switch {
+	case errors.Is(err, presence.ErrInvalidStatus):
+		h.sendErrorResponse(w, "Status must be online, away or dnd", http.StatusBadRequest)
+		return
+	case errors.Is(err, presence.ErrTextTooLong):
+		h.sendErrorResponse(w, fmt.Sprintf("Status text is longer than %d characters", presence.MaxTextLength), http.StatusBadRequest)
+		return
+	case err != nil:
+		h.Log.Error("Failed to set status", "user_id", p.ID, "error", err)
+		h.sendErrorResponse(w, "Couldn't set the status", http.StatusInternalServerError)
+		return
+	}
+
+	h.Log.Info("Status set", "user_id", p.ID, "status", res.Status)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,275 @@
+package ws
+
+import (
+	"HomeWork5/internal/message"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+)
+
+// ProtocolVersion is the version of the envelope below. Clients may omit "v";
+// frames with a newer version are rejected.
+const ProtocolVersion = 1
+
+type EventType string
+
+const (
+	// sent by clients
+	EventMessageSend    EventType = "message.send"
+	EventMessageEdit    EventType = "message.edit"
+	EventMessageDelete  EventType = "message.delete"
+	EventReactionAdd    EventType = "reaction.add"
+	EventReactionRemove EventType = "reaction.remove"
+	EventTypingStart    EventType = "typing.start"
+	EventTypingStop     EventType = "typing.stop"
+	// EventTyping is the typing.start of older clients
+	EventTyping EventType = "typing"
+	// read is also sent by the server as the read receipt of someone else
+	EventRead EventType = "read"
+
+	// sent by the server
+	EventMessage         EventType = "message"
+	EventDirectMessage   EventType = "direct.message"
+	EventMessageAck      EventType = "message.ack"
+	EventMessageUpdated  EventType = "message.updated"
+	EventMessageDeleted  EventType = "message.deleted"
+	EventThreadReply     EventType = "thread.reply"
+	EventMention         EventType = "mention"
+	EventReactionAdded   EventType = "reaction.added"
+	EventReactionRemoved EventType = "reaction.removed"
+	EventNotice          EventType = "notice"
+	EventResumed         EventType = "session.resumed"
+	EventRoomUpdated     EventType = "room.updated"
+	EventRoomDeleted     EventType = "room.deleted"
+	EventModeration      EventType = "moderation"
+	EventPresence        EventType = "presence"
+	EventError           EventType = "error"
+)
+
+const (
+	ErrCodeBadRequest         = "bad_request"
+	ErrCodeUnsupportedVersion = "unsupported_version"
+	ErrCodeUnknownType        = "unknown_type"
+	ErrCodeInvalidPayload     = "invalid_payload"
+	ErrCodeRoomArchived       = "room_archived"
+	ErrCodeMuted              = "muted"
+	ErrCodeNotFound           = "not_found"
+	ErrCodeForbidden          = "forbidden"
+	ErrCodeConflict           = "conflict"
+	ErrCodeInternal           = "internal"
+)
+
+// Envelope is every frame exchanged over the socket. ID is chosen by the
+// client and echoed back in the ack or error answering that frame.
+type Envelope struct {
+	V       int             `json:"v"`
+	Type    EventType       `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty" swaggertype:"object"`
+
+	// seq is the stored message id carried by the event, used to skip
+	// messages a user already got.
+	seq int64
+	// excludeUserID is a user whose connections the event shouldn't be
+	// echoed to.
+	excludeUserID string
+}
+
+// SendPayload is sent with message.send, ParentID makes the message a reply
+// in the thread of that message.
+type SendPayload struct {
+	Content  string `json:"content"`
+	ParentID int64  `json:"parentId,omitempty"`
+}
+
+// EditPayload is sent with message.edit, and without content with
+// message.delete.
+type EditPayload struct {
+	MessageID int64  `json:"messageId"`
+	Content   string `json:"content,omitempty"`
+}
+
+// ThreadReplyPayload is sent to the room for every reply posted to a thread,
+// with the new number of replies of the parent.
+type ThreadReplyPayload struct {
+	ParentID   int64    `json:"parentId"`
+	ReplyCount int      `json:"replyCount"`
+	Reply      *Message `json:"reply"`
+}
+
+// ReactPayload is sent with reaction.add and reaction.remove.
+type ReactPayload struct {
+	MessageID int64  `json:"messageId"`
+	Emoji     string `json:"emoji"`
+}
+
+// ReactionPayload is sent to the room with reaction.added and
+// reaction.removed: who reacted with what, and the reactions to the message
+// after the change.
+type ReactionPayload struct {
+	MessageID int64             `json:"messageId"`
+	RoomID    string            `json:"roomId"`
+	UserID    string            `json:"userId"`
+	Username  string            `json:"username"`
+	Emoji     string            `json:"emoji"`
+	Reactions message.Reactions `json:"reactions"`
+}
+
+// ReadPayload is sent with read, MessageID is the last message read, the
+// latest message of the room when it's omitted.
+type ReadPayload struct {
+	MessageID int64 `json:"messageId"`
+}
+
+// ReceiptPayload is the read receipt sent with read by the server, for a room
+// or for the direct messages of PeerID read by UserID.
+type ReceiptPayload struct {
+	RoomID     string    `json:"roomId,omitempty"`
+	PeerID     string    `json:"peerId,omitempty"`
+	UserID     string    `json:"userId"`
+	Username   string    `json:"username"`
+	LastReadID int64     `json:"lastReadId"`
+	ReadAt     time.Time `json:"readAt"`
+}
+
+type AckPayload struct {
+	MessageID int64     `json:"messageId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Notice is a system notice such as "X has joined the group".
+type Notice struct {
+	RoomID    string    `json:"roomId"`
+	UserID    string    `json:"userId"`
+	Username  string    `json:"username"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TypingPayload is sent with typing.start and typing.stop. Typing events are
+// only fanned out, never stored or replayed.
+type TypingPayload struct {
+	RoomID   string `json:"roomId"`
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+}
+
+// ResumedPayload tells a client that reconnected with lastSeenId that it has
+// got everything it missed and live delivery starts.
+type ResumedPayload struct {
+	RoomID   string `json:"roomId"`
+	FromID   int64  `json:"fromId"`
+	LastID   int64  `json:"lastId"`
+	Replayed int    `json:"replayed"`
+}
+
+// RoomDeletedPayload is sent to the members of a deleted room right before
+// they are disconnected.
+type RoomDeletedPayload struct {
+	RoomID string `json:"roomId"`
+}
+
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func newEnvelope(t EventType, id string, payload interface{}) *Envelope {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("newEnvelopeError: %v", err)
+	}
+
+	return &Envelope{V: ProtocolVersion, Type: t, ID: id, Payload: data}
+}
+
+func messageEvent(m *Message) *Envelope {
+	e := newEnvelope(EventMessage, "", m)
+	e.seq = m.ID
+	return e
+}
+
+// replyEvent carries a reply to the thread of ParentID, skipped like a
+// message by connections that already got it from a replay.
+func replyEvent(m *Message, replyCount int) *Envelope {
+	e := newEnvelope(EventThreadReply, "", ThreadReplyPayload{ParentID: m.ParentID, ReplyCount: replyCount, Reply: m})
+	e.seq = m.ID
+	return e
+}
+
+func errorEvent(id, code, text string) *Envelope {
+	return newEnvelope(EventError, id, ErrorPayload{Code: code, Message: text})
+}
+
+// handleEvent processes a frame read from the client.
+func (u *User) handleEvent(h *Hub, data []byte) {
+	var e Envelope
+	if err := json.Unmarshal(data, &e); err != nil || e.Type == "" {
+		u.send(errorEvent("", ErrCodeBadRequest, "frame is not a valid envelope"))
+		return
+	}
+	if e.V > ProtocolVersion {
+		u.send(errorEvent(e.ID, ErrCodeUnsupportedVersion, "unsupported protocol version"))
+		return
+	}
+
+	switch e.Type {
+	case EventMessageSend:
+		u.handleSend(h, &e)
+	case EventMessageEdit, EventMessageDelete:
+		u.handleEdit(h, &e)
+	case EventReactionAdd, EventReactionRemove:
+		u.handleReaction(h, &e)
+	case EventRead:
+		u.handleRead(h, &e)
+	case EventTypingStart, EventTypingStop, EventTyping:
+		u.handleTyping(h, &e)
+	default:
+		u.send(errorEvent(e.ID, ErrCodeUnknownType, "unknown event type "+string(e.Type)))
+	}
+}
+
+func (u *User) handleSend(h *Hub, e *Envelope) {
+	var p SendPayload
+	if err := json.Unmarshal(e.Payload, &p); err != nil {
+		u.send(errorEvent(e.ID, ErrCodeInvalidPayload, "payload must be {\"content\": string}"))
+		return
+	}
+
+	if h.archived(u.RoomID) {
+		u.send(errorEvent(e.ID, ErrCodeRoomArchived, "the room is archived"))
+		return
+	}
+	if until, ok := h.muted(u.RoomID, u.ID); ok {
+		text := "you are muted in this room"
+		if until != nil {
+			text = "you are muted in this room until " + until.UTC().Format(time.RFC3339)
+		}
+		u.send(errorEvent(e.ID, ErrCodeMuted, text))
+		return
+	}
+
+	if err := message.ValidateContent(p.Content); err != nil {
+		u.send(errorEvent(e.ID, ErrCodeInvalidPayload, err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+
+	msg, err := h.saveMessage(ctx, u, p.Content, p.ParentID)
+	if errors.Is(err, message.ErrParentNotFound) {
+		u.send(errorEvent(e.ID, ErrCodeNotFound, "parent message not found"))
+		return
+	}
+	if err != nil {
+		log.Printf("saveMessageError: %v", err)
+		u.send(errorEvent(e.ID, ErrCodeInternal, "couldn't send the message"))
+		return
+	}
+
+	u.send(newEnvelope(EventMessageAck, e.ID, AckPayload{MessageID: msg.ID, CreatedAt: msg.CreatedAt}))
+	h.Broadcast(msg)
+}
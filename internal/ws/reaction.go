@@ -0,0 +1,224 @@
+package ws
+
+import (
+	"HomeWork5/internal/message"
+	"HomeWork5/internal/middleware"
+	"HomeWork5/internal/room"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// React adds or removes the reaction of a user to a message and lets the room
+// know on every instance.
+func (h *Hub) React(ctx context.Context, m *message.Message, userID int64, username, emoji string, add bool) (*ReactionPayload, error) {
+	const op = "ws.Hub.React"
+
+	if err := message.ValidateEmoji(emoji); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if m.DeletedAt != nil {
+		return nil, fmt.Errorf("%s: %w", op, message.ErrMessageDeleted)
+	}
+
+	rm, err := h.Room(ctx, m.RoomID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if rm.Archived() {
+		return nil, fmt.Errorf("%s: %w", op, room.ErrRoomArchived)
+	}
+
+	t := EventReactionRemoved
+	if add {
+		t = EventReactionAdded
+		if _, muted := rm.mutedUntil(strconv.FormatInt(userID, 10)); muted {
+			return nil, fmt.Errorf("%s: %w", op, ErrMuted)
+		}
+		err = h.messages.AddReaction(ctx, m.ID, userID, emoji)
+	} else {
+		err = h.messages.RemoveReaction(ctx, m.ID, userID, emoji)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stored, err := h.messages.GetMessage(ctx, m.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	p := &ReactionPayload{
+		MessageID: m.ID,
+		RoomID:    m.RoomID,
+		UserID:    strconv.FormatInt(userID, 10),
+		Username:  username,
+		Emoji:     emoji,
+		Reactions: stored.Reactions,
+	}
+	if p.Reactions == nil {
+		p.Reactions = message.Reactions{}
+	}
+	h.broadcastEvent(m.RoomID, newEnvelope(t, "", p))
+
+	return p, nil
+}
+
+func (u *User) handleReaction(h *Hub, e *Envelope) {
+	var p ReactPayload
+	if err := json.Unmarshal(e.Payload, &p); err != nil || p.MessageID <= 0 {
+		u.send(errorEvent(e.ID, ErrCodeInvalidPayload, "payload must be {\"messageId\": number, \"emoji\": string}"))
+		return
+	}
+
+	userID, err := strconv.ParseInt(u.ID, 10, 64)
+	if err != nil {
+		u.send(errorEvent(e.ID, ErrCodeInternal, "couldn't change the reaction"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+
+	m, err := h.messages.GetMessage(ctx, p.MessageID)
+	if err == nil && m.RoomID != u.RoomID {
+		err = message.ErrMessageNotFound
+	}
+	if err == nil {
+		_, err = h.React(ctx, m, userID, u.Username, p.Emoji, e.Type == EventReactionAdd)
+	}
+
+	switch {
+	case errors.Is(err, message.ErrInvalidEmoji):
+		u.send(errorEvent(e.ID, ErrCodeInvalidPayload, "emoji must be a short text without spaces"))
+	case errors.Is(err, message.ErrMessageNotFound):
+		u.send(errorEvent(e.ID, ErrCodeNotFound, "message not found"))
+	case errors.Is(err, message.ErrMessageDeleted):
+		u.send(errorEvent(e.ID, ErrCodeNotFound, "the message was deleted"))
+	case errors.Is(err, message.ErrReactionNotFound):
+		u.send(errorEvent(e.ID, ErrCodeNotFound, "reaction not found"))
+	case errors.Is(err, message.ErrReactionExists):
+		u.send(errorEvent(e.ID, ErrCodeConflict, "you already reacted with this emoji"))
+	case errors.Is(err, ErrMuted):
+		u.send(errorEvent(e.ID, ErrCodeMuted, "you are muted in this room"))
+	case errors.Is(err, room.ErrRoomArchived):
+		u.send(errorEvent(e.ID, ErrCodeRoomArchived, "the room is archived"))
+	case err != nil:
+		log.Printf("reactionError: %v", err)
+		u.send(errorEvent(e.ID, ErrCodeInternal, "couldn't change the reaction"))
+	default:
+		u.send(newEnvelope(EventMessageAck, e.ID, AckPayload{MessageID: m.ID, CreatedAt: m.CreatedAt}))
+	}
+}
+
+// react runs a reaction change for the message in the path and answers with
+// the resulting reactions.
+func (h *Handler) react(w http.ResponseWriter, r *http.Request, m *message.Message, p *middleware.Principal, emoji string, add bool) {
+	res, err := h.hub.React(r.Context(), m, p.ID, p.Username, emoji, add)
+	switch {
+	case errors.Is(err, message.ErrInvalidEmoji):
+		h.sendErrorResponse(w, fmt.Sprintf("Emoji must be at most %d characters without spaces", message.MaxEmojiLength), http.StatusBadRequest)
+		return
+	case errors.Is(err, ErrMuted):
+		h.sendErrorResponse(w, "You are muted in this room", http.StatusForbidden)
+		return
+	case errors.Is(err, message.ErrMessageNotFound):
+		h.sendErrorResponse(w, "Message not found", http.StatusNotFound)
+		return
+	case errors.Is(err, message.ErrReactionNotFound):
+		h.sendErrorResponse(w, "Reaction not found", http.StatusNotFound)
+		return
+	case errors.Is(err, message.ErrReactionExists):
+		h.sendErrorResponse(w, "You already reacted with this emoji", http.StatusConflict)
+		return
+	case errors.Is(err, room.ErrRoomArchived):
+		h.sendErrorResponse(w, "The room is archived", http.StatusConflict)
+		return
+	case errors.Is(err, message.ErrMessageDeleted):
+		h.sendErrorResponse(w, "The message was deleted", http.StatusGone)
+		return
+	case err != nil:
+		h.Log.Error("Failed to change reaction", "message_id", m.ID, "error", err)
+		h.sendErrorResponse(w, "Couldn't change the reaction", http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if add {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
+
+// AddReaction godoc
+// @Summary      React to a message
+// @Description  Adds a reaction of the caller to a room message. A user reacts with each emoji at most once per message. Members of the room get a "reaction.added" event with the updated reactions.
+// @Tags         message
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id        path      int                  true  "Message ID"
+// @Param        reaction  body      message.ReactionReq  true  "Emoji"
+// @Success      201       {object}  ReactionPayload
+// @Failure      400       {object}  ErrorResponse  "Bad request"
+// @Failure      401       {object}  ErrorResponse  "Unauthorized"
+// @Failure      403       {object}  ErrorResponse  "Forbidden"
+// @Failure      404       {object}  ErrorResponse  "Message not found"
+// @Failure      409       {object}  ErrorResponse  "Already reacted or the room is archived"
+// @Failure      410       {object}  ErrorResponse  "The message was deleted"
+// @Failure      500       {object}  ErrorResponse  "Internal error"
+// @Router       /messages/{id}/reactions [post]
+func (h *Handler) AddReaction(w http.ResponseWriter, r *http.Request) {
+	m, p, ok := h.loadMessage(w, r)
+	if !ok {
+		return
+	}
+
+	var req message.ReactionReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	h.react(w, r, m, p, req.Emoji, true)
+}
+
+// RemoveReaction godoc
+// @Summary      Remove a reaction
+// @Description  Removes a reaction of the caller from a room message. Members of the room get a "reaction.removed" event with the updated reactions.
+// @Tags         message
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id     path      int     true  "Message ID"
+// @Param        emoji  path      string  true  "Emoji, URL-encoded"
+// @Success      200    {object}  ReactionPayload
+// @Failure      400    {object}  ErrorResponse  "Bad request"
+// @Failure      401    {object}  ErrorResponse  "Unauthorized"
+// @Failure      403    {object}  ErrorResponse  "Forbidden"
+// @Failure      404    {object}  ErrorResponse  "Message or reaction not found"
+// @Failure      409    {object}  ErrorResponse  "The room is archived"
+// @Failure      410    {object}  ErrorResponse  "The message was deleted"
+// @Failure      500    {object}  ErrorResponse  "Internal error"
+// @Router       /messages/{id}/reactions/{emoji} [delete]
+func (h *Handler) RemoveReaction(w http.ResponseWriter, r *http.Request) {
+	emoji, err := url.PathUnescape(chi.URLParam(r, "emoji"))
+	if err != nil {
+		h.sendErrorResponse(w, "Invalid emoji", http.StatusBadRequest)
+		return
+	}
+
+	m, p, ok := h.loadMessage(w, r)
+	if !ok {
+		return
+	}
+
+	h.react(w, r, m, p, emoji, false)
+}
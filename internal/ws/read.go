@@ -0,0 +1,171 @@
+package ws
+
+import (
+	"HomeWork5/internal/message"
+	"HomeWork5/internal/middleware"
+	"HomeWork5/internal/room"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MarkRead moves the read position of the user in the room to the message,
+// or to the latest message when messageID is 0, and sends a read receipt to
+// the room. Positions never move back, the receipt is nil when nothing
+// changed.
+func (h *Hub) MarkRead(ctx context.Context, roomID string, userID int64, username string, messageID int64) (*message.ReadReceipt, error) {
+	const op = "ws.Hub.MarkRead"
+
+	if messageID == 0 {
+		latest, _, err := h.messages.ListRoomMessages(ctx, roomID, message.Page{Limit: 1})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if len(latest) == 0 {
+			return nil, nil
+		}
+		messageID = latest[0].ID
+	} else {
+		m, err := h.messages.GetMessage(ctx, messageID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if m.RoomID != roomID {
+			return nil, fmt.Errorf("%s: %w", op, message.ErrMessageNotFound)
+		}
+	}
+
+	lastReadID, err := h.rooms.MarkRead(ctx, roomID, userID, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if lastReadID != messageID {
+		return nil, nil
+	}
+
+	receipt := &message.ReadReceipt{
+		RoomID:     roomID,
+		UserID:     userID,
+		Username:   username,
+		LastReadID: lastReadID,
+		ReadAt:     time.Now(),
+	}
+	h.PublishReceipt(receipt)
+
+	return receipt, nil
+}
+
+// PublishReceipt sends a read receipt to the room it's about, or to both
+// sides of a direct message conversation.
+func (h *Hub) PublishReceipt(r *message.ReadReceipt) {
+	p := ReceiptPayload{
+		RoomID:     r.RoomID,
+		UserID:     strconv.FormatInt(r.UserID, 10),
+		Username:   r.Username,
+		LastReadID: r.LastReadID,
+		ReadAt:     r.ReadAt,
+	}
+
+	if r.RoomID != "" {
+		h.broadcastEvent(r.RoomID, newEnvelope(EventRead, "", p))
+		return
+	}
+
+	p.PeerID = strconv.FormatInt(r.PeerID, 10)
+	e := newEnvelope(EventRead, "", p)
+	userIDs := []string{p.PeerID, p.UserID}
+	h.deliverToUsers(userIDs, e)
+	h.publish(&BrokerMessage{UserIDs: userIDs, Event: e})
+}
+
+func (u *User) handleRead(h *Hub, e *Envelope) {
+	var p ReadPayload
+	if len(e.Payload) > 0 {
+		if err := json.Unmarshal(e.Payload, &p); err != nil || p.MessageID < 0 {
+			u.send(errorEvent(e.ID, ErrCodeInvalidPayload, "payload must be {\"messageId\": number}"))
+			return
+		}
+	}
+
+	userID, err := strconv.ParseInt(u.ID, 10, 64)
+	if err != nil {
+		u.send(errorEvent(e.ID, ErrCodeInternal, "couldn't mark the room as read"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+
+	_, err = h.MarkRead(ctx, u.RoomID, userID, u.Username, p.MessageID)
+	switch {
+	case errors.Is(err, message.ErrMessageNotFound):
+		u.send(errorEvent(e.ID, ErrCodeNotFound, "message not found"))
+	case err != nil:
+		log.Printf("markReadError: %v", err)
+		u.send(errorEvent(e.ID, ErrCodeInternal, "couldn't mark the room as read"))
+	}
+}
+
+// MarkRoomRead godoc
+// @Summary      Mark a room as read
+// @Description  Moves the read position of the caller in the room to messageId, or to the latest message without one, and sends a "read" receipt to the members of the room. Read positions never move back. Answers with the receipt, or 204 when the position didn't change.
+// @Tags         room
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id    path      string           true   "Room ID"
+// @Param        read  body      message.ReadReq  false  "Last message read"
+// @Success      200   {object}  message.ReadReceipt
+// @Success      204   {string}  string  "No Content"
+// @Failure      400   {object}  ErrorResponse  "Bad request"
+// @Failure      401   {object}  ErrorResponse  "Unauthorized"
+// @Failure      403   {object}  ErrorResponse  "Not a member of the room"
+// @Failure      404   {object}  ErrorResponse  "Room or message not found"
+// @Failure      500   {object}  ErrorResponse  "Internal error"
+// @Router       /rooms/{id}/read [post]
+func (h *Handler) MarkRoomRead(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+
+	var req message.ReadReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.MessageID < 0 {
+		h.sendErrorResponse(w, "Invalid message id", http.StatusBadRequest)
+		return
+	}
+
+	if !h.authorizeAccess(w, r, roomID) {
+		return
+	}
+	p, _ := middleware.PrincipalFromContext(r.Context())
+
+	receipt, err := h.hub.MarkRead(r.Context(), roomID, p.ID, p.Username, req.MessageID)
+	switch {
+	case errors.Is(err, message.ErrMessageNotFound):
+		h.sendErrorResponse(w, "Message not found", http.StatusNotFound)
+		return
+	case errors.Is(err, room.ErrRoomNotFound):
+		h.sendErrorResponse(w, "Room not found", http.StatusNotFound)
+		return
+	case err != nil:
+		h.Log.Error("Failed to mark room read", "room_id", roomID, "user_id", p.ID, "error", err)
+		h.sendErrorResponse(w, "Couldn't mark the room as read", http.StatusInternalServerError)
+		return
+	}
+
+	if receipt == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(receipt)
+}
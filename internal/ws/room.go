@@ -0,0 +1,273 @@
+package ws
+
+import (
+	"HomeWork5/internal/room"
+	"context"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// roomIdleTimeout is how long a room without members stays in memory before
+// its goroutine stops and it has to be loaded from storage again.
+const roomIdleTimeout = 5 * time.Minute
+
+// Room is a loaded chat room. Its members are owned by the room goroutine
+// started in run; everyone else talks to it through the channels below and
+// may only read the members under mu.
+type Room struct {
+	RoomId      string    `json:"roomId"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Topic       string    `json:"topic"`
+	HistorySize int       `json:"historySize"`
+	CreatedBy   int64     `json:"createdBy"`
+	CreatedAt   time.Time `json:"createdAt"`
+
+	// archived and visibility are read by connections and handlers,
+	// everything else above only changes on the room goroutine.
+	archived   atomic.Bool
+	visibility atomic.Value
+
+	mu    sync.RWMutex
+	users map[*User]bool
+	// muted maps the ids of muted users to the end of their mute, nil when
+	// it doesn't end.
+	muted map[string]*time.Time
+
+	typing *typingState
+
+	register   chan *User
+	unregister chan *User
+	broadcast  chan *Envelope
+	update     chan *room.Room
+	// remove carries the room.deleted event sent to the members of a deleted
+	// room before they are disconnected.
+	remove chan *Envelope
+	kick   chan kick
+	// done is closed once the room goroutine has exited, after an idle
+	// eviction or a hub shutdown.
+	done chan struct{}
+}
+
+func newRoom(r *room.Room) *Room {
+	rm := &Room{
+		RoomId:     r.ID,
+		CreatedBy:  r.CreatedBy,
+		CreatedAt:  r.CreatedAt,
+		users:      make(map[*User]bool),
+		muted:      make(map[string]*time.Time),
+		typing:     newTypingState(),
+		register:   make(chan *User),
+		unregister: make(chan *User),
+		broadcast:  make(chan *Envelope),
+		update:     make(chan *room.Room),
+		remove:     make(chan *Envelope),
+		kick:       make(chan kick),
+		done:       make(chan struct{}),
+	}
+	rm.apply(r)
+	return rm
+}
+
+// apply copies the editable settings of the stored room.
+func (r *Room) apply(stored *room.Room) {
+	r.Name = stored.Name
+	r.Description = stored.Description
+	r.Topic = stored.Topic
+	r.HistorySize = stored.HistorySize
+	r.archived.Store(stored.ArchivedAt != nil)
+	r.visibility.Store(stored.Visibility)
+}
+
+func (r *Room) Archived() bool {
+	return r.archived.Load()
+}
+
+func (r *Room) Visibility() room.Visibility {
+	v, _ := r.visibility.Load().(room.Visibility)
+	return v
+}
+
+func (r *Room) run(ctx context.Context, h *Hub) {
+	defer h.wg.Done()
+	defer close(r.done)
+
+	idle := time.NewTimer(roomIdleTimeout)
+	idleC := idle.C
+	defer idle.Stop()
+
+	for {
+		select {
+		case u := <-r.register:
+			r.registerUserInRoom(u)
+			h.replayHistory(ctx, r, u)
+			idle.Stop()
+			idleC = nil
+		case u := <-r.unregister:
+			if msg := r.unregisterUserInRoom(u); msg != nil {
+				r.broadcastToUserRoom(msg)
+			}
+			if r.size() == 0 {
+				idle.Reset(roomIdleTimeout)
+				idleC = idle.C
+			}
+		case message := <-r.broadcast:
+			r.broadcastToUserRoom(message)
+		case stored := <-r.update:
+			r.apply(stored)
+		case k := <-r.kick:
+			r.closeUser(k.userID, websocket.ClosePolicyViolation, k.reason)
+		case deleted := <-r.remove:
+			r.broadcastToUserRoom(deleted)
+			r.broadcastToUserRoom(newEnvelope(EventNotice, "", Notice{
+				RoomID:    r.RoomId,
+				Content:   fmt.Sprintf("The room %s was deleted", r.Name),
+				CreatedAt: time.Now(),
+			}))
+			r.closeAllWith(websocket.CloseGoingAway, "room was deleted")
+			h.evict(r)
+			return
+		case <-idleC:
+			h.evict(r)
+			return
+		case <-ctx.Done():
+			r.closeAllWith(websocket.CloseGoingAway, "server is shutting down")
+			return
+		}
+	}
+}
+
+func (r *Room) size() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.users)
+}
+
+// members returns a snapshot of the connected users.
+func (r *Room) members() []*User {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]*User, 0, len(r.users))
+	for u := range r.users {
+		users = append(users, u)
+	}
+	return users
+}
+
+func (r *Room) connected(userID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for u := range r.users {
+		if u.ID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Room) registerUserInRoom(u *User) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.users[u] = true
+}
+
+func (r *Room) unregisterUserInRoom(u *User) *Envelope {
+	r.mu.Lock()
+	_, ok := r.users[u]
+	delete(r.users, u)
+	remaining := len(r.users)
+	r.mu.Unlock()
+
+	u.close()
+
+	// a user may be connected from several tabs, only say goodbye once the
+	// last one is gone
+	if !ok || remaining == 0 || r.connected(u.ID) {
+		return nil
+	}
+
+	return noticeEvent(u, "%s has left the group")
+}
+
+// noticeEvent builds a system notice about u; format gets the username.
+func noticeEvent(u *User, format string) *Envelope {
+	return newEnvelope(EventNotice, "", Notice{
+		RoomID:    u.RoomID,
+		UserID:    u.ID,
+		Username:  u.Username,
+		Content:   fmt.Sprintf(format, u.Username),
+		CreatedAt: time.Now(),
+	})
+}
+
+func (r *Room) broadcastToUserRoom(e *Envelope) {
+	for _, u := range r.members() {
+		if e.excludeUserID != "" && u.ID == e.excludeUserID {
+			continue
+		}
+		if e.seq != 0 && u.replayedIDs[e.seq] {
+			continue
+		}
+		u.send(e)
+	}
+}
+
+// kick asks the room goroutine to disconnect every connection of a user.
+type kick struct {
+	userID string
+	reason string
+}
+
+// closeUser closes the connections of a user, they leave the room through
+// the usual unregistration once their readers notice.
+func (r *Room) closeUser(userID string, code int, text string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for u := range r.users {
+		if u.ID == userID {
+			u.closeWith(code, text)
+		}
+	}
+}
+
+// mutedUntil reports whether the user is muted and until when, a nil time
+// meaning for good.
+func (r *Room) mutedUntil(userID string) (*time.Time, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	until, ok := r.muted[userID]
+	if ok && until != nil && !until.After(time.Now()) {
+		return nil, false
+	}
+	return until, ok
+}
+
+func (r *Room) setMuted(userID string, until *time.Time, muted bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if muted {
+		r.muted[userID] = until
+	} else {
+		delete(r.muted, userID)
+	}
+}
+
+func (r *Room) closeAllWith(code int, text string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for u := range r.users {
+		u.closeWith(code, text)
+		delete(r.users, u)
+	}
+}
@@ -0,0 +1,141 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"time"
+)
+
+// ClosePayload is the data of the last SSE event sent before the server ends
+// the stream, it mirrors the WebSocket close frame.
+type ClosePayload struct {
+	Code   int    `json:"code"`
+	Reason string `json:"reason"`
+}
+
+// sseStream writes envelopes as server-sent events.
+type sseStream struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+	u  *User
+}
+
+func (s *sseStream) write(e *Envelope) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.writeRaw(e.seq, string(e.Type), data)
+}
+
+// writeRaw writes a single event. The id is the stored message id so the
+// browser sends it back as Last-Event-ID when it reconnects.
+func (s *sseStream) writeRaw(id int64, event string, data []byte) error {
+	s.rc.SetWriteDeadline(s.u.writeDeadline())
+	if id > 0 {
+		if _, err := fmt.Fprintf(s.w, "id: %d\n", id); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+func (s *sseStream) ping() error {
+	s.rc.SetWriteDeadline(s.u.writeDeadline())
+	if _, err := fmt.Fprint(s.w, ": ping\n\n"); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+func (s *sseStream) writeClose() error {
+	s.u.mu.Lock()
+	p := ClosePayload{Code: s.u.closeCode, Reason: s.u.closeText}
+	s.u.mu.Unlock()
+
+	data, _ := json.Marshal(p)
+	return s.writeRaw(0, "close", data)
+}
+
+// Events godoc
+// @Summary      Stream room events
+// @Description  Server-sent events fallback for clients that can't open a WebSocket. Every event carries the same envelope as the WebSocket protocol in its data, the event name is the envelope type and message and thread.reply events have the message id as the event id. Reconnecting with Last-Event-ID (or lastSeenId) resumes without gaps. A final "close" event is sent when the server ends the stream.
+// @Tags         room
+// @Produce      text/event-stream
+// @Security     BearerAuth
+// @Param        id          path      string  true   "Room ID"
+// @Param        lastSeenId  query     int     false  "Id of the last message received, to resume from"
+// @Param        invite      query     string  false  "Invite code, accepted when the caller isn't a member yet"
+// @Success      200         {string}  string  "Event stream"
+// @Failure      400         {object}  ErrorResponse  "Bad request"
+// @Failure      401         {object}  ErrorResponse  "Unauthorized"
+// @Failure      403         {object}  ErrorResponse  "Not a member of the room"
+// @Failure      404         {object}  ErrorResponse  "Room not found"
+// @Router       /rooms/{id}/events [get]
+func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+	lastSeenID := r.Header.Get("Last-Event-ID")
+	if lastSeenID == "" {
+		lastSeenID = r.URL.Query().Get("lastSeenId")
+	}
+
+	req, ok := h.prepareJoin(w, r, roomID, lastSeenID)
+	if !ok {
+		return
+	}
+
+	user := req.newUser(h.hub.cfg, nil)
+	stream := &sseStream{w: w, rc: http.NewResponseController(w), u: user}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := stream.rc.Flush(); err != nil {
+		h.Log.Error("Streaming is not supported", "room_id", roomID, "error", err)
+		return
+	}
+
+	if req.lastSeenID > 0 {
+		if err := h.hub.CatchUp(r.Context(), user, req.lastSeenID, stream.write); err != nil {
+			h.Log.Error("Failed to catch up", "user_id", user.ID, "room_id", roomID, "error", err)
+			return
+		}
+	}
+
+	if err := h.hub.Join(r.Context(), user); err != nil {
+		h.Log.Error("Failed to register user", "user_id", user.ID, "room_id", roomID, "error", err)
+		return
+	}
+	defer h.hub.Unregister(user)
+
+	h.Log.Info("User subscribed to room events", "user_id", user.ID, "room_id", roomID, "username", user.Username)
+
+	ticker := time.NewTicker(user.cfg.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case e, ok := <-user.Message:
+			if !ok {
+				stream.writeClose()
+				return
+			}
+			if err := stream.write(e); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := stream.ping(); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
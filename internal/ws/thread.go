@@ -0,0 +1,82 @@
+package ws
+
+import (
+	"HomeWork5/internal/message"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ThreadRes is the message that started a thread with a page of its replies.
+type ThreadRes struct {
+	Parent  *Message   `json:"parent"`
+	Replies []*Message `json:"replies"`
+	HasMore bool       `json:"hasMore"`
+}
+
+// Thread returns a page of the thread the message belongs to, whether it
+// started it or replied in it.
+func (h *Hub) Thread(ctx context.Context, m *message.Message, page message.Page) (*ThreadRes, error) {
+	const op = "ws.Hub.Thread"
+
+	if m.ParentID != nil {
+		parent, err := h.messages.GetMessage(ctx, *m.ParentID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		m = parent
+	}
+
+	stored, hasMore, err := h.messages.ListReplies(ctx, m.ID, page)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	replies := make([]*Message, 0, len(stored))
+	for _, reply := range stored {
+		replies = append(replies, toMessage(reply))
+	}
+
+	return &ThreadRes{Parent: toMessage(m), Replies: replies, HasMore: hasMore}, nil
+}
+
+// GetThread godoc
+// @Summary      Get a thread
+// @Description  Returns the message that started the thread of the given message, with its replies ordered from oldest to newest. Pass the id of the first returned reply as "before" to scroll back, or the id of the last one as "after" to catch up. New replies are delivered to the room as "thread.reply" events.
+// @Tags         message
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      int  true   "Message ID"
+// @Param        before  query     int  false  "Return replies with id lower than this"
+// @Param        after   query     int  false  "Return replies with id greater than this"
+// @Param        limit   query     int  false  "Page size, 50 by default and 100 at most"
+// @Success      200     {object}  ThreadRes
+// @Failure      400     {object}  ErrorResponse  "Bad request"
+// @Failure      401     {object}  ErrorResponse  "Unauthorized"
+// @Failure      403     {object}  ErrorResponse  "Not a member of the room"
+// @Failure      404     {object}  ErrorResponse  "Message not found"
+// @Failure      500     {object}  ErrorResponse  "Internal error"
+// @Router       /messages/{id}/thread [get]
+func (h *Handler) GetThread(w http.ResponseWriter, r *http.Request) {
+	page, err := message.ParsePage(r)
+	if err != nil {
+		h.sendErrorResponse(w, "Invalid pagination parameters", http.StatusBadRequest)
+		return
+	}
+
+	m, _, ok := h.loadMessage(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := h.hub.Thread(r.Context(), m, page)
+	if err != nil {
+		h.Log.Error("Failed to load thread", "message_id", m.ID, "error", err)
+		h.sendErrorResponse(w, "Couldn't load the thread", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,118 @@
+package ws
+
+import (
+	"sync"
+	"time"
+)
+
+// typingState tracks who is typing in a room on this instance, so that the
+// starts of a user are fanned out at most once per TypingInterval and typing
+// stops on its own after TypingTimeout.
+type typingState struct {
+	mu    sync.Mutex
+	users map[string]*typist
+	// now tells the time the throttling and expiry go by.
+	now func() time.Time
+}
+
+type typist struct {
+	// sentAt is when the last typing.start was fanned out, lastAt when the
+	// last one was received.
+	sentAt time.Time
+	lastAt time.Time
+	timer  *time.Timer
+}
+
+func newTypingState() *typingState {
+	return &typingState{users: make(map[string]*typist), now: time.Now}
+}
+
+// start records a typing.start of the user and reports whether it should be
+// fanned out. expire runs once the user has been idle for timeout.
+func (t *typingState) start(userID string, interval, timeout time.Duration, expire func()) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	ty, ok := t.users[userID]
+	if !ok {
+		ty = &typist{timer: time.AfterFunc(timeout, expire)}
+		t.users[userID] = ty
+	} else {
+		ty.timer.Reset(timeout)
+	}
+	ty.lastAt = now
+
+	if now.Sub(ty.sentAt) < interval {
+		return false
+	}
+	ty.sentAt = now
+	return true
+}
+
+// stop forgets the user and reports whether they were typing.
+func (t *typingState) stop(userID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ty, ok := t.users[userID]
+	if !ok {
+		return false
+	}
+	ty.timer.Stop()
+	delete(t.users, userID)
+	return true
+}
+
+// expire forgets the user unless a start arrived while the timer fired.
+func (t *typingState) expire(userID string, timeout time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ty, ok := t.users[userID]
+	if !ok || t.now().Sub(ty.lastAt) < timeout {
+		return false
+	}
+	delete(t.users, userID)
+	return true
+}
+
+func typingEvent(t EventType, u *User) *Envelope {
+	e := newEnvelope(t, "", TypingPayload{RoomID: u.RoomID, UserID: u.ID, Username: u.Username})
+	e.excludeUserID = u.ID
+	return e
+}
+
+// handleTyping fans typing.start and typing.stop out to the other members of
+// the room. Starts are throttled and expire without a stop; users that can't
+// send to the room are ignored.
+func (u *User) handleTyping(h *Hub, e *Envelope) {
+	r, ok := h.lookup(u.RoomID)
+	if !ok {
+		return
+	}
+
+	if e.Type == EventTypingStop {
+		if r.typing.stop(u.ID) {
+			h.broadcastEvent(u.RoomID, typingEvent(EventTypingStop, u))
+		}
+		return
+	}
+
+	if r.Archived() {
+		return
+	}
+	if _, muted := r.mutedUntil(u.ID); muted {
+		return
+	}
+
+	cfg := u.cfg
+	expire := func() {
+		if r.typing.expire(u.ID, cfg.TypingTimeout) {
+			h.broadcastEvent(u.RoomID, typingEvent(EventTypingStop, u))
+		}
+	}
+	if r.typing.start(u.ID, cfg.TypingInterval, cfg.TypingTimeout, expire) {
+		h.broadcastEvent(u.RoomID, typingEvent(EventTypingStart, u))
+	}
+}
@@ -0,0 +1,143 @@
+package ws
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock stands in for time.Now in typingState; timers are given long
+// timeouts so that only the clock decides.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestTypingState() (*typingState, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)}
+	s := newTypingState()
+	s.now = clock.Now
+	return s, clock
+}
+
+func TestTypingStateStart(t *testing.T) {
+	const (
+		interval = 3 * time.Second
+		timeout  = time.Hour
+	)
+
+	tests := []struct {
+		name string
+		// gaps is how much time passes before each start after the first
+		gaps []time.Duration
+		want []bool
+	}{
+		{name: "first start is fanned out", want: []bool{true}},
+		{
+			name: "starts within the interval are throttled",
+			gaps: []time.Duration{time.Second, time.Second},
+			want: []bool{true, false, false},
+		},
+		{
+			name: "a start after the interval is fanned out",
+			gaps: []time.Duration{interval, interval},
+			want: []bool{true, true, true},
+		},
+		{
+			name: "the interval counts from the last start fanned out",
+			gaps: []time.Duration{2 * time.Second, 2 * time.Second, time.Second},
+			want: []bool{true, false, true, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, clock := newTestTypingState()
+			defer s.stop("1")
+
+			for i, want := range tt.want {
+				if i > 0 {
+					clock.advance(tt.gaps[i-1])
+				}
+				if got := s.start("1", interval, timeout, func() {}); got != want {
+					t.Fatalf("start #%d = %v, want %v", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTypingStateStop(t *testing.T) {
+	tests := []struct {
+		name    string
+		started bool
+		want    bool
+	}{
+		{name: "typing user", started: true, want: true},
+		{name: "user not typing", started: false, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestTypingState()
+			if tt.started {
+				s.start("1", time.Second, time.Hour, func() {})
+			}
+
+			if got := s.stop("1"); got != tt.want {
+				t.Fatalf("stop = %v, want %v", got, tt.want)
+			}
+			if s.stop("1") {
+				t.Fatal("second stop = true, want false")
+			}
+		})
+	}
+}
+
+func TestTypingStateExpire(t *testing.T) {
+	const timeout = 6 * time.Second
+
+	tests := []struct {
+		name string
+		idle time.Duration
+		// restart starts again right before the expiry check, as when a start
+		// arrives while the timer fires
+		restart bool
+		stopped bool
+		want    bool
+	}{
+		{name: "user idle for the timeout expires", idle: timeout, want: true},
+		{name: "user idle for less than the timeout keeps typing", idle: timeout - time.Second, want: false},
+		{name: "start racing the timer keeps the user typing", idle: timeout, restart: true, want: false},
+		{name: "stopped user doesn't expire", idle: timeout, stopped: true, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, clock := newTestTypingState()
+
+			s.start("1", 0, time.Hour, func() {})
+			clock.advance(tt.idle)
+			if tt.restart {
+				s.start("1", 0, time.Hour, func() {})
+			}
+			if tt.stopped {
+				s.stop("1")
+			}
+
+			if got := s.expire("1", timeout); got != tt.want {
+				t.Fatalf("expire = %v, want %v", got, tt.want)
+			}
+			// expired and stopped users are forgotten
+			if got, want := s.stop("1"), !tt.want && !tt.stopped; got != want {
+				t.Fatalf("still typing = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -1,56 +1,225 @@
 package ws
 
 import (
+	"HomeWork5/internal/message"
 	"github.com/gorilla/websocket"
 	"log"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type User struct {
 	ID       string `json:"id"`
 	Username string `json:"username"`
 	RoomID   string `json:"roomId"`
-	Message  chan *Message
-	Con      *websocket.Conn
+	// admin lets the user moderate any room.
+	admin bool
+	// Message is the bounded send queue drained by writeMessage.
+	Message chan *Envelope
+	Con     *websocket.Conn
+
+	// replayedIDs holds the stored messages replayed to the user, live events
+	// carrying one of them are duplicates. Ids don't commit in order, so
+	// anything not in the set is still delivered; owned by the room goroutine
+	// once registered.
+	replayedIDs map[int64]bool
+	// lastID is the newest message replayed to the user.
+	lastID int64
+	// resumeFrom is the lastSeenId the client reconnected with and replayed
+	// how many messages CatchUp wrote before registration.
+	resumeFrom int64
+	replayed   int
+
+	cfg     Config
+	dropped atomic.Uint64
+
+	// mu guards Message against sends racing with close; closeCode and
+	// closeText are written to the client once the queue is drained.
+	mu        sync.Mutex
+	closed    bool
+	closeCode int
+	closeText string
 }
 
+// Message is the payload of message and direct.message events. Direct
+// messages have RecipientID set and no room; History marks messages replayed
+// on join. Deleted messages come with DeletedAt and no content. Replies have
+// the message that started their thread as ParentID.
 type Message struct {
-	Content  string `json:"content"`
-	RoomID   string `json:"roomId"`
-	Username string `json:"username"`
+	ID          int64             `json:"id,omitempty"`
+	Content     string            `json:"content"`
+	RoomID      string            `json:"roomId"`
+	UserID      string            `json:"userId,omitempty"`
+	Username    string            `json:"username"`
+	RecipientID string            `json:"recipientId,omitempty"`
+	ParentID    int64             `json:"parentId,omitempty"`
+	ReplyCount  int               `json:"replyCount,omitempty"`
+	Reactions   message.Reactions `json:"reactions,omitempty"`
+	Mentions    message.Mentions  `json:"mentions,omitempty"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	EditedAt    *time.Time        `json:"editedAt,omitempty"`
+	DeletedAt   *time.Time        `json:"deletedAt,omitempty"`
+	History     bool              `json:"history,omitempty"`
+}
+
+func newUser(cfg Config, id, username, roomID string, con *websocket.Conn) *User {
+	return &User{
+		ID:       id,
+		Username: username,
+		RoomID:   roomID,
+		Message:  make(chan *Envelope, cfg.SendQueueSize),
+		Con:      con,
+		cfg:      cfg,
+
+		replayedIDs: make(map[int64]bool),
+	}
+}
+
+// markReplayed records that the message with the given id was replayed to
+// the user, it reports false if it already was.
+func (u *User) markReplayed(id int64) bool {
+	if u.replayedIDs[id] {
+		return false
+	}
+	u.replayedIDs[id] = true
+	u.lastID = max(u.lastID, id)
+	return true
 }
 
+// Dropped returns how many messages were not delivered to this connection
+// because its send queue was full.
+func (u *User) Dropped() uint64 {
+	return u.dropped.Load()
+}
+
+// send queues a message for the writer without blocking. When the queue is
+// full the overflow policy applies; it's a no-op once the user is closed.
+func (u *User) send(e *Envelope) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.closed {
+		return
+	}
+
+	select {
+	case u.Message <- e:
+		return
+	default:
+	}
+
+	u.dropped.Add(1)
+	switch u.cfg.OverflowPolicy {
+	case DropOldest:
+		select {
+		case <-u.Message:
+		default:
+		}
+		select {
+		case u.Message <- e:
+		default:
+		}
+	case Disconnect:
+		// no point in flushing the backlog to a client that can't keep up
+		for len(u.Message) > 0 {
+			select {
+			case <-u.Message:
+			default:
+			}
+		}
+		u.closeLocked(websocket.ClosePolicyViolation, "send queue overflow")
+	}
+}
+
+func (u *User) close() {
+	u.closeWith(websocket.CloseNormalClosure, "")
+}
+
+// closeWith closes the send queue; the writer flushes what's left and then
+// sends a close frame with the given code.
+func (u *User) closeWith(code int, text string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.closeLocked(code, text)
+}
+
+func (u *User) closeLocked(code int, text string) {
+	if u.closed {
+		return
+	}
+	u.closed = true
+	u.closeCode = code
+	u.closeText = text
+	close(u.Message)
+}
+
+// writeMessage drains the send queue and pings the client. Any write error
+// closes the connection, which in turn fails readMessage and unregisters the
+// user.
 func (u *User) writeMessage() {
+	ticker := time.NewTicker(u.cfg.PingInterval)
 	defer func() {
+		ticker.Stop()
 		u.Con.Close()
 	}()
 
 	for {
-		if message, ok := <-u.Message; ok {
-			u.Con.WriteJSON(message)
-		} else {
-			return
+		select {
+		case e, ok := <-u.Message:
+			if !ok {
+				u.writeClose()
+				return
+			}
+			if err := u.writeEvent(e); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := u.ping(); err != nil {
+				return
+			}
 		}
 	}
 }
 
+func (u *User) writeEvent(e *Envelope) error {
+	u.Con.SetWriteDeadline(u.writeDeadline())
+	return u.Con.WriteJSON(e)
+}
+
+func (u *User) writeClose() {
+	u.mu.Lock()
+	code, text := u.closeCode, u.closeText
+	u.mu.Unlock()
+
+	u.Con.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), u.writeDeadline())
+}
+
 func (u *User) readMessage(h *Hub) {
 	defer func() {
-		h.Unregister <- u
+		h.Unregister(u)
 		u.Con.Close()
+		if n := u.Dropped(); n > 0 {
+			log.Printf("slowConsumer: user %s in room %s dropped %d messages", u.ID, u.RoomID, n)
+		}
 	}()
 
+	u.prepareRead()
+
 	for {
-		_, message, err := u.Con.ReadMessage()
+		_, data, err := u.Con.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("readMessageError: %v", err)
 			}
+			if isTimeout(err) {
+				log.Printf("heartbeat: user %s in room %s timed out", u.ID, u.RoomID)
+			}
 			break
 		}
-		h.Broadcast <- &Message{
-			Content:  string(message),
-			RoomID:   u.RoomID,
-			Username: u.Username,
-		}
+		u.extendReadDeadline()
+
+		u.handleEvent(h, data)
 	}
 }
@@ -0,0 +1,61 @@
+package ws
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestUserSendOverflow(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy OverflowPolicy
+		// want is the queue after sending "1", "2" and "3" to a queue of two
+		want        []string
+		wantClosed  bool
+		wantCode    int
+		wantDropped uint64
+	}{
+		{name: "drop oldest", policy: DropOldest, want: []string{"2", "3"}, wantDropped: 1},
+		{name: "drop newest", policy: DropNewest, want: []string{"1", "2"}, wantDropped: 1},
+		{name: "disconnect", policy: Disconnect, wantClosed: true, wantCode: websocket.ClosePolicyViolation, wantDropped: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.SendQueueSize = 2
+			cfg.OverflowPolicy = tt.policy
+			u := newUser(cfg, "1", "bob", "1", nil)
+
+			for _, id := range []string{"1", "2", "3"} {
+				u.send(&Envelope{ID: id})
+			}
+			if u.closed != tt.wantClosed {
+				t.Fatalf("closed = %v, want %v", u.closed, tt.wantClosed)
+			}
+			if tt.wantClosed {
+				if u.closeCode != tt.wantCode {
+					t.Fatalf("close code = %d, want %d", u.closeCode, tt.wantCode)
+				}
+				// sends after a disconnect are ignored
+				u.send(&Envelope{ID: "4"})
+			}
+			if got := u.Dropped(); got != tt.wantDropped {
+				t.Fatalf("Dropped() = %d, want %d", got, tt.wantDropped)
+			}
+
+			if !tt.wantClosed {
+				u.close()
+			}
+			var got []string
+			for e := range u.Message {
+				got = append(got, e.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("queue = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,50 +1,113 @@
 package ws
 
 import (
+	"HomeWork5/internal/message"
+	"HomeWork5/internal/middleware"
+	"HomeWork5/internal/room"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/websocket"
 	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	Subprotocols:    []string{tokenSubprotocol},
 	CheckOrigin: func(r *http.Request) bool {
 		return true
 	},
 }
 
 type Handler struct {
-	Log *slog.Logger
-	hub *Hub
+	Log    *slog.Logger
+	hub    *Hub
+	polls  *pollSessions
+	admins middleware.AdminChecker
 }
 
 type CreateRoomReq struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Topic       string `json:"topic"`
+	// Visibility is "public" (the default), "private" or "invite_only".
+	Visibility string `json:"visibility"`
+	// HistorySize is how many messages are replayed on join, 50 if omitted.
+	HistorySize *int `json:"historySize,omitempty"`
 }
 
 type RoomReq struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Topic       string     `json:"topic"`
+	Visibility  string     `json:"visibility"`
+	HistorySize int        `json:"historySize"`
+	CreatedBy   int64      `json:"createdBy"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ArchivedAt  *time.Time `json:"archivedAt,omitempty"`
+	MemberCount int        `json:"memberCount"`
+	OnlineCount int        `json:"onlineCount"`
+	// LastReadID and UnreadCount are the read position of the caller and
+	// the number of messages of others after it.
+	LastReadID  int64 `json:"lastReadId"`
+	UnreadCount int   `json:"unreadCount"`
+}
+
+// UpdateRoomReq changes the fields that are present. Archived switches the
+// room between read-only and normal mode.
+type UpdateRoomReq struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Topic       *string `json:"topic,omitempty"`
+	Visibility  *string `json:"visibility,omitempty"`
+	Archived    *bool   `json:"archived,omitempty"`
+}
+
+type RoomsRes struct {
+	Rooms   []*RoomReq `json:"rooms"`
+	HasMore bool       `json:"hasMore"`
+}
+
+type MessagesRes struct {
+	Messages []*Message `json:"messages"`
+	HasMore  bool       `json:"hasMore"`
 }
 
 type UserReq struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Online     bool       `json:"online"`
+	Role       string     `json:"role,omitempty"`
+	JoinedAt   *time.Time `json:"joinedAt,omitempty"`
+	LastReadID int64      `json:"lastReadId,omitempty"`
 }
 
-func NewHandler(log *slog.Logger, hub *Hub) *Handler {
+type MembersRes struct {
+	Members []*UserReq `json:"members"`
+	HasMore bool       `json:"hasMore"`
+}
+
+func NewHandler(log *slog.Logger, hub *Hub, admins middleware.AdminChecker) *Handler {
 	return &Handler{
-		Log: log,
-		hub: hub,
+		Log:    log,
+		hub:    hub,
+		polls:  newPollSessions(),
+		admins: admins,
 	}
 }
 
 func (h *Handler) sendErrorResponse(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
 	logResponseStatusError(h.Log, message, statusCode)
@@ -56,15 +119,18 @@ func logResponseStatusError(log *slog.Logger, message string, statusCode int) {
 
 // CreateRoom godoc
 // @Summary      create a room
-// @Description  create a room with id and name
+// @Description  create a persistent room with id, name and an optional description
 // @Tags         room
 // @Accept       json
 // @Produce      json
+// @Security     BearerAuth
 // @Param        user  body      CreateRoomReq  true  "User request body"
 // @Success      201   {string}  string  "Room created successfully"
 // @Failure      400   {object}  ErrorResponse    "Bad request"
+// @Failure      401   {object}  ErrorResponse    "Unauthorized"
 // @Failure      409   {object}  ErrorResponse    "Room ID already exists"
-// @Router       /rooms [post]
+// @Failure      500   {object}  ErrorResponse    "Internal error"
+// @Router       /ws/CreateRoom [post]
 func (h *Handler) CreateRoom(w http.ResponseWriter, r *http.Request) {
 	var req CreateRoomReq
 
@@ -79,16 +145,53 @@ func (h *Handler) CreateRoom(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, exists := h.hub.Rooms[req.ID]; exists {
-		h.Log.Warn("Room ID already exists", "room_id", req.ID)
-		http.Error(w, `{"error": "Room ID already exists"}`, http.StatusConflict)
+	if req.ID == "" || req.Name == "" {
+		h.sendErrorResponse(w, "Room id and name are required", http.StatusBadRequest)
+		return
+	}
+
+	historySize := room.DefaultHistorySize
+	if req.HistorySize != nil {
+		historySize = *req.HistorySize
+	}
+	if historySize < 0 || historySize > message.MaxLimit {
+		h.sendErrorResponse(w, fmt.Sprintf("History size must be between 0 and %d", message.MaxLimit), http.StatusBadRequest)
+		return
+	}
+
+	visibility := room.VisibilityPublic
+	if req.Visibility != "" {
+		visibility = room.Visibility(req.Visibility)
+	}
+	if !visibility.Valid() {
+		h.sendErrorResponse(w, "Visibility must be public, private or invite_only", http.StatusBadRequest)
+		return
+	}
+
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		h.sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
 		return
 	}
 
-	h.hub.Rooms[req.ID] = &Room{
-		RoomId: req.ID,
-		Name:   req.Name,
-		Users:  make(map[string]*User),
+	_, err = h.hub.CreateRoom(r.Context(), &room.Room{
+		ID:          req.ID,
+		Name:        req.Name,
+		Description: req.Description,
+		Topic:       req.Topic,
+		Visibility:  visibility,
+		HistorySize: historySize,
+		CreatedBy:   p.ID,
+	})
+	if errors.Is(err, room.ErrRoomExists) {
+		h.Log.Warn("Room ID already exists", "room_id", req.ID)
+		h.sendErrorResponse(w, "Room ID already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		h.Log.Error("Failed to create room", "room_id", req.ID, "error", err)
+		h.sendErrorResponse(w, "Couldn't create a room", http.StatusInternalServerError)
+		return
 	}
 
 	h.Log.Info("Room created successfully", "room_id", req.ID, "room_name", req.Name)
@@ -98,83 +201,530 @@ func (h *Handler) CreateRoom(w http.ResponseWriter, r *http.Request) {
 
 // JoinRoom godoc
 // @Summary      Join a room
-// @Description  Join an existing room using WebSocket connection with roomId, userId, and username as query parameters.
+// @Description  Join an existing room using WebSocket connection. Frames are JSON envelopes {"v": 1, "type", "id", "payload"}: clients send "message.send", "message.edit", "message.delete", "reaction.add", "reaction.remove", "read", "typing.start" and "typing.stop", the server sends "message", "direct.message", "message.ack", "message.updated", "message.deleted", "thread.reply", "mention", "reaction.added", "reaction.removed", "read", "notice", "typing.start", "typing.stop", "room.updated", "room.deleted", "moderation", "presence" and "error". The last historySize messages of the room are sent first as "message" events with "history": true. A "typing.start" is fanned out at most every 3 seconds per user; the server sends "typing.stop" itself when no start arrived for 6 seconds or the user left. Typing events are never stored. A "mention" event carries a message mentioning the caller, by name or with @room, and reaches all of their connections whichever room it was sent to. The caller is identified by the JWT passed in the token cookie, the Authorization header or the "access_token, <jwt>" Sec-WebSocket-Protocol pair.
 // @Tags         room
 // @Accept       json
 // @Produce      json
-// @Param        roomId   query     string  true  "Room ID"
-// @Param        userId   query     string  true  "User ID"
-// @Param        username query     string  true  "Username"
+// @Security     BearerAuth
+// @Param        roomId      path      string  true   "Room ID"
+// @Param        lastSeenId  query     int     false  "Id of the last message the client got before reconnecting; everything newer, thread replies included, is replayed before live delivery, followed by a session.resumed event"
+// @Param        invite      query     string  false  "Invite code, accepted when the caller isn't a member yet"
 // @Success      101      {string}  string  "Switching Protocols"
 // @Failure      400      {object}  ErrorResponse  "Bad request"
-// @Router       /rooms/join [get]
+// @Failure      401      {object}  ErrorResponse  "Unauthorized"
+// @Failure      403      {object}  ErrorResponse  "Not a member of the room or banned"
+// @Failure      404      {object}  ErrorResponse  "Room not found"
+// @Router       /ws/JoinRoom/{roomId} [get]
 func (h *Handler) JoinRoom(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "roomId")
+	if roomID == "" {
+		roomID = r.URL.Query().Get("roomId")
+	}
+
+	req, ok := h.prepareJoin(w, r, roomID, r.URL.Query().Get("lastSeenId"))
+	if !ok {
+		return
+	}
+
 	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		h.sendErrorResponse(w, "Failed to join the room", http.StatusBadRequest)
+		h.Log.Error("Failed to upgrade connection", "room_id", roomID, "error", err)
 		return
 	}
 	defer ws.Close()
 
-	roomID := r.URL.Query().Get("roomId")
-	clientID := r.URL.Query().Get("userId")
-	username := r.URL.Query().Get("username")
+	user := req.newUser(h.hub.cfg, ws)
+
+	h.Log.Info("User joined room successfully", "user_id", user.ID, "room_id", roomID, "username", user.Username)
+
+	if req.lastSeenID > 0 {
+		if err := h.hub.CatchUp(r.Context(), user, req.lastSeenID, user.writeEvent); err != nil {
+			h.Log.Error("Failed to catch up", "user_id", user.ID, "room_id", roomID, "error", err)
+			ws.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "couldn't replay missed messages"),
+				time.Now().Add(time.Second))
+			return
+		}
+	}
+
+	// the writer has to be running before registration, the hub replays the
+	// room history to the user as part of it
+	go user.writeMessage()
 
-	if roomID == "" || clientID == "" || username == "" {
-		h.sendErrorResponse(w, "Missing required query parameters", http.StatusBadRequest)
+	if err := h.hub.Join(r.Context(), user); err != nil {
+		h.Log.Error("Failed to register user", "user_id", user.ID, "room_id", roomID, "error", err)
+		user.close()
 		return
 	}
 
-	user := &User{
-		ID:       clientID,
-		Username: username,
-		RoomID:   roomID,
-		Message:  make(chan *Message),
-		Con:      ws,
+	user.readMessage(h.hub)
+}
+
+type joinReq struct {
+	roomID     string
+	lastSeenID int64
+	principal  *middleware.Principal
+}
+
+func (j *joinReq) newUser(cfg Config, con *websocket.Conn) *User {
+	u := newUser(cfg, strconv.FormatInt(j.principal.ID, 10), j.principal.Username, j.roomID, con)
+	u.admin = j.principal.Admin
+	return u
+}
+
+// prepareJoin validates a request to join a room over any transport and
+// answers it with an error status if it can't be served.
+func (h *Handler) prepareJoin(w http.ResponseWriter, r *http.Request, roomID, lastSeenID string) (*joinReq, bool) {
+	if roomID == "" {
+		h.sendErrorResponse(w, "Missing room id", http.StatusBadRequest)
+		return nil, false
 	}
+	req := &joinReq{roomID: roomID}
 
-	message := &Message{
-		Content:  fmt.Sprintf("%s has joined the group", username),
-		RoomID:   roomID,
-		Username: username,
+	if lastSeenID != "" {
+		n, err := strconv.ParseInt(lastSeenID, 10, 64)
+		if err != nil || n < 0 {
+			h.sendErrorResponse(w, "Invalid lastSeenId", http.StatusBadRequest)
+			return nil, false
+		}
+		req.lastSeenID = n
 	}
 
-	h.hub.Register <- user
-	h.hub.Broadcast <- message
+	p, err := h.authenticate(r)
+	if err != nil {
+		h.Log.Warn("Unauthorized join attempt", "room_id", roomID, "error", err)
+		h.sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return nil, false
+	}
+	req.principal = p
 
-	go user.writeMessage()
-	user.readMessage(h.hub)
+	rm, ok := h.loadRoom(w, r, roomID)
+	if !ok {
+		return nil, false
+	}
+	if !h.admit(w, r, rm, p, r.URL.Query().Get("invite")) {
+		return nil, false
+	}
+
+	return req, true
+}
+
+// PostEvent godoc
+// @Summary      Send an event to a room
+// @Description  Processes a client envelope such as "message.send" or "typing.start" the same way the WebSocket connection does, for clients on the SSE or long-polling fallbacks. The answer is the ack or error envelope, or 202 when there is none.
+// @Tags         room
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id     path      string    true  "Room ID"
+// @Param        event  body      Envelope  true  "Client envelope"
+// @Success      200    {object}  Envelope
+// @Success      202    {string}  string  "Accepted"
+// @Failure      400    {object}  ErrorResponse  "Bad request"
+// @Failure      401    {object}  ErrorResponse  "Unauthorized"
+// @Failure      403    {object}  ErrorResponse  "Not a member of the room"
+// @Failure      404    {object}  ErrorResponse  "Room not found"
+// @Router       /rooms/{id}/events [post]
+func (h *Handler) PostEvent(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.prepareJoin(w, r, chi.URLParam(r, "id"), "")
+	if !ok {
+		return
+	}
 
-	h.Log.Info("User joined room successfully", "user_id", clientID, "room_id", roomID, "username", username)
+	body, err := io.ReadAll(io.LimitReader(r.Body, h.hub.cfg.MaxMessageSize+1))
+	if err != nil || int64(len(body)) > h.hub.cfg.MaxMessageSize {
+		h.sendErrorResponse(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// a connection that is never registered, it only collects the answer
+	user := req.newUser(h.hub.cfg, nil)
+	user.handleEvent(h.hub, body)
+	user.close()
+
+	reply, ok := <-user.Message
+	if !ok {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply)
 }
 
-func (h *Hub) GetRooms() []*RoomReq {
-	allRooms := make([]*RoomReq, 0, len(h.Rooms))
+// parseListQuery reads the limit, offset and q query parameters.
+func parseListQuery(r *http.Request) (room.ListQuery, error) {
+	q := room.ListQuery{Search: strings.TrimSpace(r.URL.Query().Get("q"))}
+
+	for name, dst := range map[string]*int{"limit": &q.Limit, "offset": &q.Offset} {
+		if v := r.URL.Query().Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return q, fmt.Errorf("invalid %s %q", name, v)
+			}
+			*dst = n
+		}
+	}
+
+	return q.Normalize(), nil
+}
 
-	for _, room := range h.Rooms {
+// ListRooms godoc
+// @Summary      List rooms
+// @Description  Returns a page of rooms ordered by creation time, with the number of members and of members currently connected. Invite-only rooms are only listed to their members.
+// @Tags         room
+// @Produce      json
+// @Security     BearerAuth
+// @Param        q       query     string  false  "Case insensitive search in room names"
+// @Param        limit   query     int     false  "Page size, 50 by default and 100 at most"
+// @Param        offset  query     int     false  "Number of rooms to skip"
+// @Success      200     {object}  RoomsRes
+// @Failure      400     {object}  ErrorResponse  "Bad request"
+// @Failure      401     {object}  ErrorResponse  "Unauthorized"
+// @Failure      500     {object}  ErrorResponse  "Internal error"
+// @Router       /rooms [get]
+func (h *Handler) ListRooms(w http.ResponseWriter, r *http.Request) {
+	q, err := parseListQuery(r)
+	if err != nil {
+		h.sendErrorResponse(w, "Invalid pagination parameters", http.StatusBadRequest)
+		return
+	}
+
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		h.sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+	q.ViewerID, q.All = p.ID, p.Admin
+
+	rooms, hasMore, err := h.hub.GetRooms(r.Context(), q)
+	if err != nil {
+		h.Log.Error("Failed to list rooms", "error", err)
+		h.sendErrorResponse(w, "Couldn't list rooms", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RoomsRes{Rooms: rooms, HasMore: hasMore})
+}
+
+// UpdateRoom godoc
+// @Summary      Update a room
+// @Description  Changes the name, description or topic of a room, or archives it. Archived rooms are read-only: their history stays available but sending messages fails with a "room_archived" error event. Members get a "room.updated" event. Only the owner of the room or an admin may update it.
+// @Tags         room
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id    path      string         true  "Room ID"
+// @Param        room  body      UpdateRoomReq  true  "Fields to change"
+// @Success      200   {object}  RoomReq
+// @Failure      400   {object}  ErrorResponse  "Bad request"
+// @Failure      401   {object}  ErrorResponse  "Unauthorized"
+// @Failure      403   {object}  ErrorResponse  "Forbidden"
+// @Failure      404   {object}  ErrorResponse  "Room not found"
+// @Failure      500   {object}  ErrorResponse  "Internal error"
+// @Router       /rooms/{id} [patch]
+func (h *Handler) UpdateRoom(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+
+	var req UpdateRoomReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendErrorResponse(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	if req.Name == nil && req.Description == nil && req.Topic == nil && req.Visibility == nil && req.Archived == nil {
+		h.sendErrorResponse(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		h.sendErrorResponse(w, "Room name can't be empty", http.StatusBadRequest)
+		return
+	}
+	var visibility *room.Visibility
+	if req.Visibility != nil {
+		v := room.Visibility(*req.Visibility)
+		if !v.Valid() {
+			h.sendErrorResponse(w, "Visibility must be public, private or invite_only", http.StatusBadRequest)
+			return
+		}
+		visibility = &v
+	}
+
+	if _, _, ok := h.authorizeRoom(w, r, roomID); !ok {
+		return
+	}
+
+	stored, err := h.hub.UpdateRoom(r.Context(), roomID, room.Update{
+		Name:        req.Name,
+		Description: req.Description,
+		Topic:       req.Topic,
+		Visibility:  visibility,
+		Archived:    req.Archived,
+	})
+	if errors.Is(err, room.ErrRoomNotFound) {
+		h.sendErrorResponse(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Log.Error("Failed to update room", "room_id", roomID, "error", err)
+		h.sendErrorResponse(w, "Couldn't update the room", http.StatusInternalServerError)
+		return
+	}
+
+	h.Log.Info("Room updated successfully", "room_id", roomID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RoomReq{
+		ID:          stored.ID,
+		Name:        stored.Name,
+		Description: stored.Description,
+		Topic:       stored.Topic,
+		Visibility:  string(stored.Visibility),
+		HistorySize: stored.HistorySize,
+		CreatedBy:   stored.CreatedBy,
+		CreatedAt:   stored.CreatedAt,
+		ArchivedAt:  stored.ArchivedAt,
+		OnlineCount: len(h.hub.GetUsers(stored.ID)),
+	})
+}
+
+// DeleteRoom godoc
+// @Summary      Delete a room
+// @Description  Deletes a room with its history. Connected members get a "room.deleted" event and a notice, then the connection is closed with code 1001. Only the owner of the room or an admin may delete it, the general room can't be deleted.
+// @Tags         room
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "Room ID"
+// @Success      204  {string}  string  "No Content"
+// @Failure      400  {object}  ErrorResponse  "Bad request"
+// @Failure      401  {object}  ErrorResponse  "Unauthorized"
+// @Failure      403  {object}  ErrorResponse  "Forbidden"
+// @Failure      404  {object}  ErrorResponse  "Room not found"
+// @Failure      500  {object}  ErrorResponse  "Internal error"
+// @Router       /rooms/{id} [delete]
+func (h *Handler) DeleteRoom(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+
+	if roomID == message.GeneralRoomID {
+		h.sendErrorResponse(w, "The general room can't be deleted", http.StatusBadRequest)
+		return
+	}
+
+	if _, _, ok := h.authorizeRoom(w, r, roomID); !ok {
+		return
+	}
+
+	err := h.hub.DeleteRoom(r.Context(), roomID)
+	if errors.Is(err, room.ErrRoomNotFound) {
+		h.sendErrorResponse(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.Log.Error("Failed to delete room", "room_id", roomID, "error", err)
+		h.sendErrorResponse(w, "Couldn't delete the room", http.StatusInternalServerError)
+		return
+	}
+
+	h.Log.Info("Room deleted successfully", "room_id", roomID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorizeRoom makes sure the caller owns the room or is an admin and
+// returns both, answering the request otherwise.
+func (h *Handler) authorizeRoom(w http.ResponseWriter, r *http.Request, roomID string) (*Room, *middleware.Principal, bool) {
+	p, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		h.sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	rm, ok := h.loadRoom(w, r, roomID)
+	if !ok {
+		return nil, nil, false
+	}
+
+	if !p.Admin && rm.CreatedBy != p.ID {
+		h.sendErrorResponse(w, "Only the room owner or an admin can do that", http.StatusForbidden)
+		return nil, nil, false
+	}
+	return rm, p, true
+}
+
+// GetMembers godoc
+// @Summary      List room members
+// @Description  Returns a page of the users that joined the room, in joining order, with their role and whether they are currently connected to it.
+// @Tags         room
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      string  true   "Room ID"
+// @Param        limit   query     int     false  "Page size, 50 by default and 100 at most"
+// @Param        offset  query     int     false  "Number of members to skip"
+// @Success      200     {object}  MembersRes
+// @Failure      400     {object}  ErrorResponse  "Bad request"
+// @Failure      401     {object}  ErrorResponse  "Unauthorized"
+// @Failure      404     {object}  ErrorResponse  "Room not found"
+// @Failure      500     {object}  ErrorResponse  "Internal error"
+// @Router       /rooms/{id}/members [get]
+func (h *Handler) GetMembers(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+
+	q, err := parseListQuery(r)
+	if err != nil {
+		h.sendErrorResponse(w, "Invalid pagination parameters", http.StatusBadRequest)
+		return
+	}
+
+	if !h.authorizeAccess(w, r, roomID) {
+		return
+	}
+
+	members, hasMore, err := h.hub.GetMembers(r.Context(), roomID, q)
+	if err != nil {
+		h.Log.Error("Failed to list members", "room_id", roomID, "error", err)
+		h.sendErrorResponse(w, "Couldn't list members", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MembersRes{Members: members, HasMore: hasMore})
+}
+
+// loadRoom returns the room, answering the request if it can't be loaded.
+func (h *Handler) loadRoom(w http.ResponseWriter, r *http.Request, roomID string) (*Room, bool) {
+	rm, err := h.hub.Room(r.Context(), roomID)
+	if err != nil {
+		if errors.Is(err, room.ErrRoomNotFound) {
+			h.sendErrorResponse(w, "Room not found", http.StatusNotFound)
+			return nil, false
+		}
+		h.Log.Error("Failed to load room", "room_id", roomID, "error", err)
+		h.sendErrorResponse(w, "Couldn't load the room", http.StatusInternalServerError)
+		return nil, false
+	}
+	return rm, true
+}
+
+// GetMessages godoc
+// @Summary      Get room history
+// @Description  Returns stored messages of a room ordered from oldest to newest, without the replies in threads. Pass the id of the first returned message as "before" to scroll back, or the id of the last one as "after" to catch up.
+// @Tags         room
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      string  true   "Room ID"
+// @Param        before  query     int     false  "Return messages with id lower than this"
+// @Param        after   query     int     false  "Return messages with id greater than this"
+// @Param        limit   query     int     false  "Page size, 50 by default and 100 at most"
+// @Success      200     {object}  MessagesRes
+// @Failure      400     {object}  ErrorResponse  "Bad request"
+// @Failure      401     {object}  ErrorResponse  "Unauthorized"
+// @Failure      404     {object}  ErrorResponse  "Room not found"
+// @Failure      500     {object}  ErrorResponse  "Internal error"
+// @Router       /rooms/{id}/messages [get]
+func (h *Handler) GetMessages(w http.ResponseWriter, r *http.Request) {
+	roomID := chi.URLParam(r, "id")
+
+	page, err := message.ParsePage(r)
+	if err != nil {
+		h.sendErrorResponse(w, "Invalid pagination parameters", http.StatusBadRequest)
+		return
+	}
+
+	if !h.authorizeAccess(w, r, roomID) {
+		return
+	}
+
+	messages, hasMore, err := h.hub.History(r.Context(), roomID, page)
+	if err != nil {
+		h.Log.Error("Failed to load messages", "room_id", roomID, "error", err)
+		h.sendErrorResponse(w, "Couldn't load messages", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MessagesRes{Messages: messages, HasMore: hasMore})
+}
+
+// GetRooms returns a page of rooms with their member counts and how many of
+// the members are connected.
+func (h *Hub) GetRooms(ctx context.Context, q room.ListQuery) ([]*RoomReq, bool, error) {
+	const op = "ws.Hub.GetRooms"
+
+	rooms, hasMore, err := h.rooms.ListRooms(ctx, q)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	allRooms := make([]*RoomReq, 0, len(rooms))
+	for _, rm := range rooms {
 		allRooms = append(allRooms, &RoomReq{
-			ID:   room.RoomId,
-			Name: room.Name,
+			ID:          rm.ID,
+			Name:        rm.Name,
+			Description: rm.Description,
+			Topic:       rm.Topic,
+			Visibility:  string(rm.Visibility),
+			HistorySize: rm.HistorySize,
+			CreatedBy:   rm.CreatedBy,
+			CreatedAt:   rm.CreatedAt,
+			ArchivedAt:  rm.ArchivedAt,
+			MemberCount: rm.MemberCount,
+			OnlineCount: len(h.GetUsers(rm.ID)),
+			LastReadID:  rm.LastReadID,
+			UnreadCount: rm.UnreadCount,
 		})
 	}
 
-	return allRooms
+	return allRooms, hasMore, nil
 }
 
+// GetUsers returns the users connected to the room.
 func (h *Hub) GetUsers(roomID string) []*UserReq {
 	allUsers := make([]*UserReq, 0)
 
-	room, ok := h.Rooms[roomID]
+	r, ok := h.lookup(roomID)
 	if !ok {
-		return nil
+		return allUsers
 	}
 
-	for _, user := range room.Users {
+	seen := make(map[string]bool)
+	for _, user := range r.members() {
+		if seen[user.ID] {
+			continue
+		}
+		seen[user.ID] = true
 		allUsers = append(allUsers, &UserReq{
-			ID:   user.ID,
-			Name: user.Username,
+			ID:     user.ID,
+			Name:   user.Username,
+			Online: true,
 		})
 	}
 
 	return allUsers
 }
+
+// GetMembers returns a page of the users that joined the room, flagging the
+// ones connected to it.
+func (h *Hub) GetMembers(ctx context.Context, roomID string, q room.ListQuery) ([]*UserReq, bool, error) {
+	const op = "ws.Hub.GetMembers"
+
+	members, hasMore, err := h.rooms.ListMembers(ctx, roomID, q)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	r, _ := h.lookup(roomID)
+
+	allUsers := make([]*UserReq, 0, len(members))
+	for _, m := range members {
+		id := strconv.FormatInt(m.UserID, 10)
+		joinedAt := m.JoinedAt
+		allUsers = append(allUsers, &UserReq{
+			ID:         id,
+			Name:       m.Username,
+			Online:     r != nil && r.connected(id),
+			Role:       string(m.Role),
+			JoinedAt:   &joinedAt,
+			LastReadID: m.LastReadID,
+		})
+	}
+
+	return allUsers, hasMore, nil
+}
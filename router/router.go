@@ -1,6 +1,7 @@
 package router
 
 import (
+	"HomeWork5/internal/message"
 	"HomeWork5/internal/middleware"
 	"HomeWork5/internal/user"
 	"HomeWork5/internal/ws"
@@ -9,17 +10,73 @@ import (
 	"log/slog"
 )
 
-func InitRouter(logger *slog.Logger, userHandler *user.Handler, wsHandler *ws.Handler) *chi.Mux {
+func InitRouter(logger *slog.Logger, userHandler *user.Handler, messageHandler *message.Handler, wsHandler *ws.Handler) *chi.Mux {
 	r := chi.NewRouter()
 
 	r.Use(middleware.LoggingMiddleware(logger))
+	r.NotFound(middleware.NotFound)
+	r.MethodNotAllowed(middleware.MethodNotAllowed)
 
 	r.Post("/signup", userHandler.CreateUser)
 	r.Post("/login", userHandler.LoginUser)
 	r.Get("/logout", userHandler.LogoutUser)
 
-	r.Post("/ws/CreateRoom", wsHandler.CreateRoom)
-	r.Get("/ws/JoinRoom/:roomId", wsHandler.JoinRoom)
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.AuthMiddleware(logger, userHandler))
+
+		r.Get("/users/me", userHandler.CurrentUser)
+		r.Put("/users/me/status", wsHandler.SetStatus)
+		r.Get("/users/me/mentions", messageHandler.GetMentions)
+		r.Get("/users/{id}/presence", wsHandler.GetPresence)
+
+		r.Post("/messages", messageHandler.SendMessage)
+		r.Get("/messages", messageHandler.GetMessages)
+		r.Patch("/messages/{id}", wsHandler.EditMessage)
+		r.Delete("/messages/{id}", wsHandler.DeleteMessage)
+		r.Get("/messages/{id}/history", wsHandler.GetMessageHistory)
+		r.Get("/messages/{id}/thread", wsHandler.GetThread)
+		r.Post("/messages/{id}/reactions", wsHandler.AddReaction)
+		r.Delete("/messages/{id}/reactions/{emoji}", wsHandler.RemoveReaction)
+		r.Get("/users/messages", messageHandler.GetConversations)
+		r.Post("/users/{id}/messages", messageHandler.SendDirectMessage)
+		r.Get("/users/{id}/messages", messageHandler.GetConversation)
+		r.Post("/users/{id}/messages/read", messageHandler.MarkConversationRead)
+
+		r.Post("/ws/CreateRoom", wsHandler.CreateRoom)
+		r.Get("/rooms", wsHandler.ListRooms)
+		r.Patch("/rooms/{id}", wsHandler.UpdateRoom)
+		r.Delete("/rooms/{id}", wsHandler.DeleteRoom)
+		r.Get("/rooms/{id}/members", wsHandler.GetMembers)
+		r.Get("/rooms/{id}/messages", wsHandler.GetMessages)
+		r.Get("/rooms/{id}/events", wsHandler.Events)
+		r.Post("/rooms/{id}/events", wsHandler.PostEvent)
+		r.Get("/rooms/{id}/poll", wsHandler.Poll)
+		r.Post("/rooms/{id}/read", wsHandler.MarkRoomRead)
+
+		r.Post("/rooms/{id}/invites", wsHandler.CreateInvite)
+		r.Delete("/rooms/{id}/invites/{code}", wsHandler.RevokeInvite)
+		r.Get("/invites/{code}", wsHandler.GetInvite)
+		r.Post("/invites/{code}/accept", wsHandler.AcceptInvite)
+		r.Post("/rooms/{id}/requests", wsHandler.RequestJoin)
+		r.Get("/rooms/{id}/requests", wsHandler.ListJoinRequests)
+		r.Post("/rooms/{id}/requests/{userId}/approve", wsHandler.ApproveJoinRequest)
+		r.Post("/rooms/{id}/requests/{userId}/reject", wsHandler.RejectJoinRequest)
+
+		r.Post("/rooms/{id}/members/{userId}/kick", wsHandler.Kick)
+		r.Put("/rooms/{id}/members/{userId}/role", wsHandler.SetRole)
+		r.Get("/rooms/{id}/bans", wsHandler.ListBans)
+		r.Put("/rooms/{id}/bans/{userId}", wsHandler.Ban)
+		r.Delete("/rooms/{id}/bans/{userId}", wsHandler.Unban)
+		r.Get("/rooms/{id}/mutes", wsHandler.ListMutes)
+		r.Put("/rooms/{id}/mutes/{userId}", wsHandler.Mute)
+		r.Delete("/rooms/{id}/mutes/{userId}", wsHandler.Unmute)
+		r.Get("/rooms/{id}/moderation", wsHandler.GetModerationLog)
+	})
+
+	// JoinRoom authenticates on its own so that browsers can pass the token as a
+	// WebSocket subprotocol, which AuthMiddleware doesn't look at.
+	r.Get("/ws/JoinRoom/{roomId}", wsHandler.JoinRoom)
+
 	r.Get("/swagger/*", httpSwagger.WrapHandler)
 
 	return r